@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"service/common"
+	"service/common/graph"
+	"service/common/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Error codes of v2 envelope
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeMissingData      = "missing_data"
+	CodeInvalidData      = "invalid_data"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeCanceled         = "canceled"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+)
+
+const (
+	versionKey = "api.version"
+	startKey   = "api.start"
+	metaKey    = "api.meta"
+)
+
+//Error is a machine-readable error of v2 envelope
+type Error struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+//Meta describes v2 response
+type Meta struct {
+	Timings    map[string]float64 `json:"timings"` //milliseconds
+	Counts     map[string]int     `json:"counts,omitempty"`
+	Datasets   map[string]string  `json:"datasets,omitempty"`
+	RequestIDs map[string]string  `json:"requestIds,omitempty"`
+}
+
+//Envelope is v2 response body
+type Envelope struct {
+	Data   interface{} `json:"data"`
+	Meta   *Meta       `json:"meta"`
+	Errors []Error     `json:"errors"`
+}
+
+//V2 middleware makes handlers of a group respond with Envelope
+func V2() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionKey, 2)
+		c.Set(startKey, time.Now())
+		c.Set(metaKey, &Meta{Timings: make(map[string]float64)})
+		c.Next()
+	}
+}
+
+//IsV2 reports if request is served by v2 api
+func IsV2(c *gin.Context) bool {
+	return c.GetInt(versionKey) == 2
+}
+
+func meta(c *gin.Context) *Meta {
+	if m, ok := c.Get(metaKey); ok {
+		return m.(*Meta)
+	}
+	return &Meta{Timings: make(map[string]float64)}
+}
+
+//Dataset records dataset id and RequestId of loaded data in meta under name, e.g. data, a or b
+func Dataset(c *gin.Context, name string, id string, data *common.AirFareSearchResponse) {
+	if !IsV2(c) {
+		return
+	}
+	m := meta(c)
+	if id != "" {
+		if m.Datasets == nil {
+			m.Datasets = make(map[string]string)
+		}
+		m.Datasets[name] = id
+	}
+	if data != nil && data.RequestID != "" {
+		if m.RequestIDs == nil {
+			m.RequestIDs = make(map[string]string)
+		}
+		m.RequestIDs[name] = data.RequestID
+	}
+}
+
+//Timing records duration of a handler step since start in meta
+func Timing(c *gin.Context, name string, start time.Time) {
+	if IsV2(c) {
+		meta(c).Timings[name] = milliseconds(time.Since(start))
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func finish(c *gin.Context) *Meta {
+	m := meta(c)
+	if start, ok := c.Get(startKey); ok {
+		m.Timings["total"] = milliseconds(time.Since(start.(time.Time)))
+	}
+	return m
+}
+
+//Respond writes payload: as is with success flag in v1, as data of Envelope in v2.
+//Meta counts lengths of payload slices
+func Respond(c *gin.Context, status int, payload gin.H) {
+	if !IsV2(c) {
+		payload["success"] = true
+		c.JSON(status, payload)
+		return
+	}
+
+	m := finish(c)
+	for key, value := range payload {
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Slice:
+			if m.Counts == nil {
+				m.Counts = make(map[string]int)
+			}
+			m.Counts[key] = v.Len()
+		}
+	}
+	c.JSON(status, Envelope{Data: payload, Meta: m, Errors: []Error{}})
+}
+
+//Fail writes error: {"success": false, "error": message} merged with extra in v1, Envelope with
+//errors and extra as data in v2
+func Fail(c *gin.Context, status int, code string, field string, err error, extra ...gin.H) {
+	if !IsV2(c) {
+		body := gin.H{"success": false, "error": err.Error()}
+		for _, e := range extra {
+			for key, value := range e {
+				body[key] = value
+			}
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	var data interface{}
+	if len(extra) > 0 {
+		data = extra[0]
+	}
+	c.JSON(status, Envelope{Data: data, Meta: finish(c), Errors: []Error{{Code: code, Field: field, Message: err.Error()}}})
+}
+
+var bindingField = regexp.MustCompile(`Key: '[^.']+\.([^']+)'`)
+
+//BindError writes binding error of req. Validation errors are reported per field by form tag
+func BindError(c *gin.Context, err error, req interface{}) {
+	matches := bindingField.FindAllStringSubmatch(err.Error(), -1)
+	if !IsV2(c) || len(matches) == 0 {
+		Fail(c, http.StatusBadRequest, CodeInvalidRequest, "", err)
+		return
+	}
+
+	var errs []Error
+	for idx, match := range matches {
+		message := err.Error()
+		if lines := strings.Split(message, "\n"); idx < len(lines) {
+			message = lines[idx]
+		}
+		errs = append(errs, Error{Code: CodeInvalidParameter, Field: formField(reflect.TypeOf(req), match[1]), Message: message})
+	}
+	c.JSON(http.StatusBadRequest, Envelope{Meta: finish(c), Errors: errs})
+}
+
+//formField returns form tag of struct field by name, embedded structs are searched too. Name may be
+//qualified by embedded struct names
+func formField(t reflect.Type, name string) string {
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return name
+	}
+	if field, ok := t.FieldByName(name); ok {
+		if tag := strings.Split(field.Tag.Get("form"), ",")[0]; tag != "" {
+			return tag
+		}
+	}
+	return name
+}
+
+//LoadError writes error of common.LoadData or common.OpenData. Side is empty for data and dataset_id
+//inputs, a or b for data_a and dataset_a or data_b and dataset_b
+func LoadError(c *gin.Context, side string, err error) {
+	data, dataset := "data", "dataset_id"
+	if side != "" {
+		data, dataset = "data_"+side, "dataset_"+side
+	}
+
+	switch e := err.(type) {
+	case *common.InputError:
+		inputErrors(c, data, common.InputErrors{e})
+		return
+	case common.InputErrors:
+		inputErrors(c, data, e)
+		return
+	}
+
+	switch {
+	case err == common.ErrNoData:
+		Fail(c, common.LoadDataStatus(err), CodeMissingData, data, err)
+	case err == store.ErrNotFound:
+		Fail(c, common.LoadDataStatus(err), CodeNotFound, dataset, err)
+	case err == store.ErrInvalidID:
+		Fail(c, common.LoadDataStatus(err), CodeInvalidParameter, dataset, err)
+	default:
+		Fail(c, common.LoadDataStatus(err), CodeInternal, "", err)
+	}
+}
+
+//inputErrors writes rejected input errors with their codes and positions
+func inputErrors(c *gin.Context, field string, errs common.InputErrors) {
+	if !IsV2(c) {
+		Fail(c, http.StatusBadRequest, CodeInvalidData, field, errs)
+		return
+	}
+
+	var list []Error
+	for _, e := range errs {
+		message := e.Message
+		if e.Line == 0 && e.Offset > 0 {
+			message = e.Error()
+		}
+		list = append(list, Error{Code: e.Code, Field: field, Message: message, Line: e.Line, Column: e.Column})
+	}
+	c.JSON(http.StatusBadRequest, Envelope{Meta: finish(c), Errors: list})
+}
+
+//StoreError writes error of store operations
+func StoreError(c *gin.Context, field string, err error) {
+	switch {
+	case err == store.ErrNotFound:
+		Fail(c, http.StatusNotFound, CodeNotFound, field, err)
+	case err == store.ErrInvalidID || err == store.ErrInvalidName:
+		Fail(c, http.StatusBadRequest, CodeInvalidParameter, field, err)
+	default:
+		Fail(c, http.StatusInternalServerError, CodeInternal, "", err)
+	}
+}
+
+//SearchError writes error of abandoned route search: 504 when request deadline is exceeded, 408 when client is gone
+func SearchError(c *gin.Context, err error) {
+	switch err {
+	case context.DeadlineExceeded:
+		Fail(c, http.StatusGatewayTimeout, CodeTimeout, "", err)
+	case context.Canceled:
+		Fail(c, http.StatusRequestTimeout, CodeCanceled, "", err)
+	default:
+		Fail(c, http.StatusInternalServerError, CodeInternal, "", err)
+	}
+}
+
+//Headers of report state which formats other than json have no place for
+const (
+	HeaderCollisions = "X-Collisions"
+	HeaderTruncated  = "X-Search-Truncated"
+	HeaderReason     = "X-Search-Reason"
+)
+
+//Report writes report rendered in contentType. Counts of colliding keys of a and b are sent in X-Collisions
+//header, truncation of route search in X-Search-Truncated and X-Search-Reason when stats are given
+func Report(c *gin.Context, contentType string, content []byte, collisionsA int, collisionsB int, stats *graph.SearchStats) {
+	c.Header(HeaderCollisions, fmt.Sprintf("a=%d, b=%d", collisionsA, collisionsB))
+	if stats != nil {
+		c.Header(HeaderTruncated, strconv.FormatBool(stats.Truncated))
+		if stats.Reason != "" {
+			c.Header(HeaderReason, stats.Reason)
+		}
+	}
+	c.Data(http.StatusOK, contentType, content)
+}
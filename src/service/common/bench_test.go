@@ -0,0 +1,141 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/r3labs/diff"
+)
+
+//benchSnapshot generates AirFareSearchResponse of n single flight itineraries. Mutated snapshot retimes,
+//reprices, reclassifies or drops some of the flights
+func benchSnapshot(b *testing.B, n int, seed int64, mutate bool) *AirFareSearchResponse {
+	airports := []string{"DXB", "DEL", "BKK", "SIN", "KUL", "HKG", "DOH", "IST", "LHR", "CDG"}
+	carriers := []string{"AirIndia", "Emirates", "Thai", "Singapore", "Qatar"}
+	rnd := rand.New(rand.NewSource(seed))
+	mut := rand.New(rand.NewSource(seed + 1))
+	start := time.Date(2018, 10, 22, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	buf.WriteString(`<AirFareSearchResponse RequestTime="28-09-2015 20:23:49" ResponseTime="28-09-2015 20:23:56"><RequestId>bench</RequestId><PricedItineraries>`)
+	for idx := 0; idx < n; idx++ {
+		source := airports[rnd.Intn(len(airports))]
+		destination := airports[rnd.Intn(len(airports))]
+		departure := start.Add(time.Duration(rnd.Intn(14*24*60)) * time.Minute)
+		arrival := departure.Add(time.Duration(60+rnd.Intn(12*60)) * time.Minute)
+		class, price, charges := "G", 100+rnd.Intn(900), true
+
+		if mutate {
+			switch mut.Intn(10) {
+			case 0:
+				price += 10 + mut.Intn(100)
+			case 1:
+				departure = departure.Add(30 * time.Minute)
+				arrival = arrival.Add(30 * time.Minute)
+			case 2:
+				class = "Y"
+			case 3:
+				charges = false
+			case 4:
+				continue
+			}
+		}
+
+		fmt.Fprintf(&buf, `<Flights><OnwardPricedItinerary><Flights><Flight><Carrier id="%d">%s</Carrier><FlightNumber>%d</FlightNumber><Source>%s</Source><Destination>%s</Destination><DepartureTimeStamp>%s</DepartureTimeStamp><ArrivalTimeStamp>%s</ArrivalTimeStamp><Class>%s</Class><NumberOfStops>0</NumberOfStops><FareBasis>%d</FareBasis><WarningText></WarningText><TicketType>E</TicketType></Flight></Flights></OnwardPricedItinerary>`,
+			idx%len(carriers), carriers[idx%len(carriers)], idx, source, destination, departure.Format("2006-01-02T1504"), arrival.Format("2006-01-02T1504"), class, idx)
+		fmt.Fprintf(&buf, `<Pricing currency="SGD">`)
+		if charges {
+			fmt.Fprintf(&buf, `<ServiceCharges type="SingleAdult" ChargeType="BaseFare">%d</ServiceCharges><ServiceCharges type="SingleAdult" ChargeType="AirlineTaxes">100</ServiceCharges>`, price-100)
+		}
+		fmt.Fprintf(&buf, `<ServiceCharges type="SingleAdult" ChargeType="TotalAmount">%d</ServiceCharges></Pricing></Flights>`, price)
+	}
+	buf.WriteString(`</PricedItineraries></AirFareSearchResponse>`)
+
+	data, err := ParseAirFareSearchResponse(buf.Bytes())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+//benchItems returns flights of both snapshots present in each of them, by keys
+func benchItems(b *testing.B, n int) ([]string, map[string]*FlightItem, map[string]*FlightItem) {
+	items := func(data *AirFareSearchResponse) map[string]*FlightItem {
+		result := make(map[string]*FlightItem)
+		for p := range data.PricedItineraries.Flights {
+			f := &data.PricedItineraries.Flights[p]
+			for idx := range f.OnwardPricedItinerary.Flights.Flight {
+				flight := &f.OnwardPricedItinerary.Flights.Flight[idx]
+				result[flight.Key()] = &FlightItem{Flight: flight, Pricing: &f.Pricing}
+			}
+		}
+		return result
+	}
+	itemsA, itemsB := items(benchSnapshot(b, n, 1, false)), items(benchSnapshot(b, n, 1, true))
+
+	var keys []string
+	for key := range itemsA {
+		if _, ok := itemsB[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, itemsA, itemsB
+}
+
+func BenchmarkDiffFlightItems(b *testing.B) {
+	keys, itemsA, itemsB := benchItems(b, 10000)
+	b.Run("reflection", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, key := range keys {
+				diff.Diff(*itemsA[key], *itemsB[key])
+			}
+		}
+	})
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, key := range keys {
+				DiffFlightItems(itemsA[key], itemsB[key])
+			}
+		}
+	})
+}
+
+func BenchmarkDiffRoutes(b *testing.B) {
+	keys, itemsA, itemsB := benchItems(b, 10000)
+	var routesA, routesB []*Route
+	for idx := 0; idx+1 < len(keys); idx += 2 {
+		routesA = append(routesA, &Route{Flights: []*FlightItem{itemsA[keys[idx]], itemsA[keys[idx+1]]}})
+		routesB = append(routesB, &Route{Flights: []*FlightItem{itemsB[keys[idx]], itemsB[keys[idx+1]]}})
+	}
+
+	b.Run("reflection", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for idx := range routesA {
+				diff.Diff(*routesA[idx], *routesB[idx])
+			}
+		}
+	})
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for idx := range routesA {
+				DiffRoutes(routesA[idx], routesB[idx])
+			}
+		}
+	})
+}
+
+func BenchmarkFlightsListDiff(b *testing.B) {
+	listA, listB := NewFlightsList(benchSnapshot(b, 10000, 1, false)), NewFlightsList(benchSnapshot(b, 10000, 1, true))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		listB.Diff(listA, nil)
+	}
+}
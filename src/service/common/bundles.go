@@ -0,0 +1,279 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/r3labs/diff"
+)
+
+//Bundle is a supplier priced itinerary: onward and return legs sold for a single price
+type Bundle struct {
+	Key     string    `json:"key"`
+	Onward  []*Flight `json:"onward" diff:"onward"`
+	Return  []*Flight `json:"return" diff:"return"`
+	Pricing *Pricing  `json:"pricing" diff:"pricing"`
+}
+
+func legsKey(flights []*Flight) string {
+	keys := make([]string, len(flights))
+	for idx, f := range flights {
+		keys[idx] = f.Key()
+	}
+	return strings.Join(keys, ",")
+}
+
+//legsShape identifies legs by their origin, final destination and departure date
+func legsShape(flights []*Flight) string {
+	if len(flights) == 0 {
+		return ""
+	}
+	first, last := flights[0], flights[len(flights)-1]
+	return first.Source + "-" + last.Destination + ":" + first.DepartureTimeStamp.Format("01-02-2006")
+}
+
+//NewBundles creates bundles by data from AirFareSearchResponse keyed by their leg sequence. Duplicates are
+//overwritten by the last one
+func NewBundles(data *AirFareSearchResponse) map[string]*Bundle {
+	bundles, _, _ := NewBundlesWithPolicy(data, DuplicatesOverwrite)
+	return bundles
+}
+
+//NewBundlesWithPolicy creates bundles by data from AirFareSearchResponse keyed by their leg sequence.
+//Bundles of the same legs priced differently are resolved due duplicates policy and reported as collisions.
+//Bundle Key is the key it is kept by
+func NewBundlesWithPolicy(data *AirFareSearchResponse, policy string) (map[string]*Bundle, []BundleCollision, error) {
+	var items []Bundle
+	var keys []string
+	for p := range data.PricedItineraries.Flights {
+		f := &data.PricedItineraries.Flights[p]
+		b := Bundle{Pricing: &f.Pricing}
+		for idx := range f.OnwardPricedItinerary.Flights.Flight {
+			b.Onward = append(b.Onward, &f.OnwardPricedItinerary.Flights.Flight[idx])
+		}
+		for idx := range f.ReturnPricedItinerary.Flights.Flight {
+			b.Return = append(b.Return, &f.ReturnPricedItinerary.Flights.Flight[idx])
+		}
+		b.Key = legsKey(b.Onward) + "|" + legsKey(b.Return)
+		items = append(items, b)
+		keys = append(keys, b.Key)
+	}
+
+	kept, collisionKeys, groups, err := resolveDuplicates(keys, func(idx int) (float32, bool) {
+		return items[idx].Pricing.GetTotalAmount()
+	}, policy)
+
+	var collisions []BundleCollision
+	for _, key := range collisionKeys {
+		collision := BundleCollision{Key: key}
+		for _, idx := range groups[key] {
+			collision.Bundles = append(collision.Bundles, items[idx])
+		}
+		collisions = append(collisions, collision)
+	}
+	if err != nil {
+		return nil, collisions, err
+	}
+
+	bundles := make(map[string]*Bundle)
+	for key, idx := range kept {
+		b := items[idx]
+		b.Key = key
+		bundles[key] = &b
+	}
+	return bundles, collisions, nil
+}
+
+//BundleUpdate is a bundle present in both snapshots with its changes
+type BundleUpdate struct {
+	Bundle            Bundle         `json:"origin"`
+	Changelog         diff.Changelog `json:"changes"`
+	Events            []ChangeEvent  `json:"events"`
+	PriceDelta        *float32       `json:"priceDelta,omitempty"`
+	PriceDeltaPercent *float32       `json:"priceDeltaPercent,omitempty"`
+}
+
+//LegSubstitution is a leg replaced by another one at the same position
+type LegSubstitution struct {
+	Direction string  `json:"direction"` //onward or return
+	Index     int     `json:"index"`
+	Old       *Flight `json:"old,omitempty"`
+	New       *Flight `json:"new,omitempty"`
+}
+
+//BundleSubstitution is a disappeared bundle replaced by an appeared one of the same shape
+type BundleSubstitution struct {
+	Old               Bundle            `json:"old"`
+	New               Bundle            `json:"new"`
+	Legs              []LegSubstitution `json:"legs"`
+	PriceDelta        *float32          `json:"priceDelta,omitempty"`
+	PriceDeltaPercent *float32          `json:"priceDeltaPercent,omitempty"`
+}
+
+func priceDelta(a *Pricing, b *Pricing) (*float32, *float32) {
+	from, okA := a.GetTotalAmount()
+	to, okB := b.GetTotalAmount()
+	if !okA || !okB {
+		return nil, nil
+	}
+	delta := to - from
+	if from == 0 {
+		return &delta, nil
+	}
+	percent := delta / from * 100
+	return &delta, &percent
+}
+
+func substituteLegs(direction string, a []*Flight, b []*Flight) []LegSubstitution {
+	var legs []LegSubstitution
+	for idx := 0; idx < len(a) || idx < len(b); idx++ {
+		var old, updated *Flight
+		if idx < len(a) {
+			old = a[idx]
+		}
+		if idx < len(b) {
+			updated = b[idx]
+		}
+		if old != nil && updated != nil && old.Key() == updated.Key() {
+			continue
+		}
+		legs = append(legs, LegSubstitution{direction, idx, old, updated})
+	}
+	return legs
+}
+
+//ClassifyBundleChanges converts changelog of Bundle into typed events
+func ClassifyBundleChanges(origin *Bundle, changelog diff.Changelog) []ChangeEvent {
+	var events []ChangeEvent
+	for idx, change := range changelog {
+		item := &FlightItem{Pricing: origin.Pricing}
+		path := change.Path
+		if len(path) > 2 && (path[0] == "onward" || path[0] == "return") {
+			legs := origin.Onward
+			if path[0] == "return" {
+				legs = origin.Return
+			}
+			if pos, err := strconv.Atoi(path[1]); err == nil && pos < len(legs) {
+				item.Flight = legs[pos]
+				path = append([]string{"flight"}, path[2:]...)
+			}
+		}
+		event := classifyChange(item, change.Type, path, change.From, change.To)
+		event.change = idx
+		if item.Flight != nil {
+			event.Flight = item.Flight.Key()
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+//bundlesList is a map of bundles by keys compared by diffKeyed
+type bundlesList map[string]*Bundle
+
+func (bl bundlesList) keys() []string {
+	keys := make([]string, 0, len(bl))
+	for key := range bl {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (bl bundlesList) has(key string) bool {
+	_, ok := bl[key]
+	return ok
+}
+
+func (bl bundlesList) compare(key string, other keyedList) diff.Changelog {
+	return diffBundle(bl[key], other.(bundlesList)[key])
+}
+
+//DiffBundles compares bundles of two snapshots. Bundles with the same leg sequence are matched,
+//unmatched ones of the same shape are paired as substitutions
+func DiffBundles(bundlesA map[string]*Bundle, bundlesB map[string]*Bundle, options *CompareOptions) (additions []Bundle, removals []Bundle, updates []BundleUpdate, substitutions []BundleSubstitution) {
+	added, removed, changed := diffKeyed(bundlesList(bundlesA), bundlesList(bundlesB), WorkersCount, options)
+
+	for _, key := range added {
+		additions = append(additions, *bundlesB[key])
+	}
+	for _, key := range removed {
+		removals = append(removals, *bundlesA[key])
+	}
+	for _, change := range changed {
+		bundleA, bundleB := bundlesA[change.key], bundlesB[change.key]
+		update := BundleUpdate{
+			Bundle:    *bundleA,
+			Changelog: change.changelog,
+			Events:    ClassifyBundleChanges(bundleA, change.changelog),
+		}
+		update.PriceDelta, update.PriceDeltaPercent = priceDelta(bundleA.Pricing, bundleB.Pricing)
+		updates = append(updates, update)
+	}
+
+	substitutions, additions, removals = pairSubstitutions(additions, removals)
+	return
+}
+
+//pairSubstitutions pairs removed and added bundles of the same shape sharing most legs
+func pairSubstitutions(additions []Bundle, removals []Bundle) (substitutions []BundleSubstitution, restAdditions []Bundle, restRemovals []Bundle) {
+	shape := func(b *Bundle) string {
+		return legsShape(b.Onward) + "|" + legsShape(b.Return)
+	}
+	shared := func(a *Bundle, b *Bundle) int {
+		keys := make(map[string]bool)
+		for _, f := range append(append([]*Flight{}, a.Onward...), a.Return...) {
+			keys[f.Key()] = true
+		}
+		count := 0
+		for _, f := range append(append([]*Flight{}, b.Onward...), b.Return...) {
+			if keys[f.Key()] {
+				count++
+			}
+		}
+		return count
+	}
+
+	paired := make([]bool, len(additions))
+	for r := range removals {
+		old := &removals[r]
+		best, bestShared := -1, -1
+		for a := range additions {
+			if paired[a] || shape(old) != shape(&additions[a]) {
+				continue
+			}
+			if s := shared(old, &additions[a]); s > bestShared {
+				best, bestShared = a, s
+			}
+		}
+		if best < 0 {
+			restRemovals = append(restRemovals, *old)
+			continue
+		}
+
+		paired[best] = true
+		updated := &additions[best]
+		substitution := BundleSubstitution{Old: *old, New: *updated}
+		substitution.Legs = append(substituteLegs("onward", old.Onward, updated.Onward), substituteLegs("return", old.Return, updated.Return)...)
+		substitution.PriceDelta, substitution.PriceDeltaPercent = priceDelta(old.Pricing, updated.Pricing)
+		substitutions = append(substitutions, substitution)
+	}
+
+	for a := range additions {
+		if !paired[a] {
+			restAdditions = append(restAdditions, additions[a])
+		}
+	}
+	return
+}
+
+//FilterBundleUpdates keeps events not lower than severity and drops updates left without events
+func FilterBundleUpdates(updates []BundleUpdate, severity string) []BundleUpdate {
+	var filtered []BundleUpdate
+	for _, update := range updates {
+		update.Events = FilterEvents(update.Events, severity)
+		if len(update.Events) > 0 {
+			filtered = append(filtered, update)
+		}
+	}
+	return filtered
+}
@@ -8,28 +8,169 @@ import (
 	"time"
 )
 
+//DatasetDataRequest is a multipart/form-data binding
+type DatasetDataRequest struct {
+	Data *multipart.FileHeader `form:"data" binding:"required"`
+}
+
 //SingleDataRequest is a multipart/form-data binding
 type SingleDataRequest struct {
-	Data              *multipart.FileHeader `form:"data" binding:"required"`
+	Data              *multipart.FileHeader `form:"data"`
+	DatasetID         string                `form:"dataset_id"`
 	Source            string                `form:"source" binding:"required"`
 	Destination       string                `form:"destination" binding:"required"`
 	MaxFlightsInRoute int                   `form:"max_flights_in_route"`
+
+	SearchBudgetRequest
+}
+
+//ListDataRequest is a multipart/form-data binding
+type ListDataRequest struct {
+	SingleDataRequest
+
+	Stream string `form:"stream"` //ndjson or sse, routes are written as soon as found
+}
+
+//RankDataRequest is a multipart/form-data binding
+type RankDataRequest struct {
+	SingleDataRequest
+
+	Criteria string `form:"criteria"` //comma separated names, all criteria by default
+}
+
+//CountDataRequest is a multipart/form-data binding
+type CountDataRequest struct {
+	SingleDataRequest
+
+	Mode string `form:"mode"` //exact or estimate
 }
 
 //CompareDataRequest is a multipart/form-data binding
 type CompareDataRequest struct {
-	DataA *multipart.FileHeader `form:"data_a" binding:"required"`
-	DataB *multipart.FileHeader `form:"data_b" binding:"required"`
+	DataA    *multipart.FileHeader `form:"data_a"`
+	DataB    *multipart.FileHeader `form:"data_b"`
+	DatasetA string                `form:"dataset_a"`
+	DatasetB string                `form:"dataset_b"`
+
+	CompareOptionsRequest
+
+	MatchKey       string `form:"match_key"`
+	MatchFields    string `form:"match_fields"`
+	ScheduleWindow int    `form:"schedule_window"` //minutes, pairs retimed flights when greater than zero
+	MinSeverity    string `form:"min_severity"`
+	Summary        bool   `form:"summary"`
+	Format         string `form:"format"`     //json, jsonpatch, csv or html
+	Duplicates     string `form:"duplicates"` //keep_all (default), overwrite, keep_cheapest or fail
+	Stream         bool   `form:"stream"`     //ndjson streaming comparison with bounded memory
 }
 
 //CompareRoutesDataRequest is a multipart/form-data binding
 type CompareRoutesDataRequest struct {
-	DataA *multipart.FileHeader `form:"data_a" binding:"required"`
-	DataB *multipart.FileHeader `form:"data_b" binding:"required"`
+	DataA    *multipart.FileHeader `form:"data_a"`
+	DataB    *multipart.FileHeader `form:"data_b"`
+	DatasetA string                `form:"dataset_a"`
+	DatasetB string                `form:"dataset_b"`
+
+	Source            string `form:"source" binding:"required"`
+	Destination       string `form:"destination" binding:"required"`
+	MaxFlightsInRoute int    `form:"max_flights_in_route"`
+
+	SearchBudgetRequest
+	CompareOptionsRequest
+
+	ScheduleWindow int    `form:"schedule_window"` //minutes, reports retimed flights when greater than zero
+	MinSeverity    string `form:"min_severity"`
+	Format         string `form:"format"`     //json, jsonpatch, csv or html
+	Duplicates     string `form:"duplicates"` //keep_all (default), overwrite, keep_cheapest or fail
+}
+
+//CompareRankDataRequest is a multipart/form-data binding
+type CompareRankDataRequest struct {
+	DataA    *multipart.FileHeader `form:"data_a"`
+	DataB    *multipart.FileHeader `form:"data_b"`
+	DatasetA string                `form:"dataset_a"`
+	DatasetB string                `form:"dataset_b"`
 
 	Source            string `form:"source" binding:"required"`
 	Destination       string `form:"destination" binding:"required"`
 	MaxFlightsInRoute int    `form:"max_flights_in_route"`
+
+	SearchBudgetRequest
+}
+
+//CompareItinerariesDataRequest is a multipart/form-data binding
+type CompareItinerariesDataRequest struct {
+	DataA    *multipart.FileHeader `form:"data_a"`
+	DataB    *multipart.FileHeader `form:"data_b"`
+	DatasetA string                `form:"dataset_a"`
+	DatasetB string                `form:"dataset_b"`
+
+	CompareOptionsRequest
+
+	MinSeverity string `form:"min_severity"`
+	Duplicates  string `form:"duplicates"` //keep_all (default), overwrite, keep_cheapest or fail
+}
+
+//MultiCityDataRequest is a multipart/form-data binding. Legs is a json array of Leg
+type MultiCityDataRequest struct {
+	Data              *multipart.FileHeader `form:"data"`
+	DatasetID         string                `form:"dataset_id"`
+	Legs              string                `form:"legs" binding:"required"`
+	MaxFlightsInRoute int                   `form:"max_flights_in_route"`
+
+	SearchBudgetRequest
+}
+
+//BatchDataRequest is a multipart/form-data binding. Queries is a json array of BatchQuery
+type BatchDataRequest struct {
+	Data      *multipart.FileHeader `form:"data"`
+	DatasetID string                `form:"dataset_id"`
+	Queries   string                `form:"queries" binding:"required"`
+
+	SearchBudgetRequest
+}
+
+//BatchQuery is a single list or rank query of batch request
+type BatchQuery struct {
+	ID                string `json:"id"`
+	Type              string `json:"type"`
+	Source            string `json:"source"`
+	Destination       string `json:"destination"`
+	MaxFlightsInRoute int    `json:"max_flights_in_route"`
+}
+
+//ValidateDataRequest is a multipart/form-data binding
+type ValidateDataRequest struct {
+	Data      *multipart.FileHeader `form:"data"`
+	DatasetID string                `form:"dataset_id"`
+	FailOn    string                `form:"fail_on"` //severity which fails the check with 422
+}
+
+//SnapshotDataRequest is a multipart/form-data binding
+type SnapshotDataRequest struct {
+	Series    string                `form:"series" binding:"required"`
+	Data      *multipart.FileHeader `form:"data"`
+	DatasetID string                `form:"dataset_id"`
+}
+
+//HistoryRequest is a query binding
+type HistoryRequest struct {
+	Series string `form:"series" binding:"required"`
+}
+
+//WatchRequest is a form or json binding of price watch
+type WatchRequest struct {
+	Type              string  `form:"type" json:"type" binding:"required"`
+	Series            string  `form:"series" json:"series"`
+	Source            string  `form:"source" json:"source"`
+	Destination       string  `form:"destination" json:"destination"`
+	MaxFlightsInRoute int     `form:"max_flights_in_route" json:"max_flights_in_route"`
+	Carrier           string  `form:"carrier" json:"carrier"`
+	FlightNumber      string  `form:"flight_number" json:"flight_number"`
+	Threshold         float32 `form:"threshold" json:"threshold"`
+	Currency          string  `form:"currency" json:"currency"`
+	Percent           float32 `form:"percent" json:"percent"`
+	WebhookURL        string  `form:"webhook_url" json:"webhook_url"`
 }
 
 //Timestamp time.Time with unmarshal 2006-01-02T1504 support
@@ -40,11 +181,14 @@ type Timestamp struct {
 //UnmarshalXML "2006-01-02T1504" to Timestamp
 func (t *Timestamp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	const format = "2006-01-02T1504"
+	offset := d.InputOffset()
 	var str string
-	d.DecodeElement(&str, &start)
+	if err := d.DecodeElement(&str, &start); err != nil {
+		return err
+	}
 	parsed, err := time.Parse(format, str)
 	if err != nil {
-		return err
+		return &InputError{Code: CodeBadTimestamp, Message: fmt.Sprintf("%s: cannot parse %q as %s", start.Name.Local, str, format), Offset: offset}
 	}
 	*t = Timestamp{parsed}
 	return nil
@@ -82,14 +226,17 @@ type PricedItinerary struct {
 	Flights Flights `xml:"Flights"`
 }
 
+//ServiceCharge accessory structure
+type ServiceCharge struct {
+	Amount     float32 `xml:",chardata"  json:"amount" diff:"amount"`
+	Type       string  `xml:"type,attr"  json:"type" diff:"type"`
+	ChargeType string  `xml:"ChargeType,attr"  json:"chargeType" diff:"chargeType"`
+}
+
 //Pricing accessory structure
 type Pricing struct {
-	Currency       string `xml:"currency,attr"  json:"currency" diff:"currency"`
-	ServiceCharges []struct {
-		Amount     float32 `xml:",chardata"  json:"amount" diff:"amount"`
-		Type       string  `xml:"type,attr"  json:"type" diff:"type"`
-		ChargeType string  `xml:"ChargeType,attr"  json:"chargeType" diff:"chargeType"`
-	} `xml:"ServiceCharges"  json:"serviceCharges" diff:"serviceCharges"`
+	Currency       string          `xml:"currency,attr"  json:"currency" diff:"currency"`
+	ServiceCharges []ServiceCharge `xml:"ServiceCharges"  json:"serviceCharges" diff:"serviceCharges"`
 }
 
 //GetTotalAmount returns total flight cost
@@ -105,18 +252,21 @@ func (p *Pricing) GetTotalAmount() (amount float32, ok bool) {
 	return
 }
 
+//PricedFlights is a priced onward and return itinerary
+type PricedFlights struct {
+	OnwardPricedItinerary PricedItinerary `xml:"OnwardPricedItinerary"`
+	ReturnPricedItinerary PricedItinerary `xml:"ReturnPricedItinerary"`
+
+	Pricing Pricing `xml:"Pricing"`
+}
+
 //AirFareSearchResponse xml binding
 type AirFareSearchResponse struct {
 	RequestTime       string `xml:"RequestTime,attr"`
 	ResponseTime      string `xml:"ResponseTime,attr"`
 	RequestID         string `xml:"RequestId"`
 	PricedItineraries struct {
-		Flights []struct {
-			OnwardPricedItinerary PricedItinerary `xml:"OnwardPricedItinerary"`
-			ReturnPricedItinerary PricedItinerary `xml:"ReturnPricedItinerary"`
-
-			Pricing Pricing `xml:"Pricing"`
-		} `xml:"Flights"`
+		Flights []PricedFlights `xml:"Flights"`
 	} `xml:"PricedItineraries"`
 }
 
@@ -125,7 +275,8 @@ const TransferTimeInMinutes = 60
 
 //Route is a list of FlightItem
 type Route struct {
-	Flights []*FlightItem `json:"flights" diff:"flights"`
+	Flights  []*FlightItem `json:"flights" diff:"flights"`
+	MatchKey string        `json:"matchKey,omitempty" diff:"-"` //RoutesList key of diff results
 }
 
 //Key returns Route's Flights composite key
@@ -136,19 +287,56 @@ func (r *Route) Key() (key string) {
 	return
 }
 
+//GetTotalAmount returns total route cost
+func (r *Route) GetTotalAmount() (amount float32, ok bool) {
+	for _, f := range r.Flights {
+		price, exist := f.Pricing.GetTotalAmount()
+		if !exist {
+			return 0, false
+		}
+		amount += price
+	}
+	return amount, len(r.Flights) > 0
+}
+
+//NewRoute creates Route by path of FlightItem edges
+func NewRoute(path *graph.Path) Route {
+	var flights []*FlightItem
+	for _, edge := range path.Edges() {
+		flights = append(flights, edge.(*FlightItem))
+	}
+	return Route{Flights: flights}
+}
+
 //FlightItem stores info about Flight and it's Pricing
 type FlightItem struct {
-	Flight  *Flight  `json:"flight" diff:"flight"`
-	Pricing *Pricing `json:"pricing" diff:"pricing"`
+	Flight   *Flight  `json:"flight" diff:"flight"`
+	Pricing  *Pricing `json:"pricing" diff:"pricing"`
+	MatchKey string   `json:"matchKey,omitempty" diff:"-"` //FlightsList key of diff results
 }
 
 //IsAccessibleFrom detects if flight is available due arrival and departure time
 func (f *FlightItem) IsAccessibleFrom(from interface{}) bool {
-	nextFlightDepartureTime := f.Flight.DepartureTimeStamp.Time.Add(-TransferTimeInMinutes * time.Minute) //need some time for transshipment
-	return (from.(*FlightItem)).Flight.ArrivalTimeStamp.Before(nextFlightDepartureTime)
+	return (from.(*FlightItem)).ConnectsTo(f.Flight.DepartureTimeStamp.Time)
+}
+
+//ConnectsTo detects if there is time for transshipment from flight to a flight departing at departure
+func (f *FlightItem) ConnectsTo(departure time.Time) bool {
+	nextFlightDepartureTime := departure.Add(-TransferTimeInMinutes * time.Minute) //need some time for transshipment
+	return f.Flight.ArrivalTimeStamp.Before(nextFlightDepartureTime)
+}
+
+//DepartureTime returns flight departure, FlightItem is a graph.TimedEdge
+func (f *FlightItem) DepartureTime() time.Time {
+	return f.Flight.DepartureTimeStamp.Time
+}
+
+//ArrivalTime returns flight arrival
+func (f *FlightItem) ArrivalTime() time.Time {
+	return f.Flight.ArrivalTimeStamp.Time
 }
 
-//NewFlightsGraph creates graph by data from AirFareSearchResponse
+//NewFlightsGraph creates graph by data from AirFareSearchResponse, indexed by departures
 func NewFlightsGraph(data *AirFareSearchResponse) *graph.Graph {
 	var flights []FlightItem
 	nodes := make(map[string]bool)
@@ -175,5 +363,6 @@ func NewFlightsGraph(data *AirFareSearchResponse) *graph.Graph {
 	for idx, item := range flights {
 		g.AddEdge(item.Flight.Source, item.Flight.Destination, &flights[idx])
 	}
+	g.Index()
 	return g
 }
@@ -0,0 +1,328 @@
+package common
+
+import (
+	"strconv"
+
+	"github.com/r3labs/diff"
+)
+
+//Typed comparators produce the same change records as reflection based diff.Diff: fields are
+//reported by their diff tags, unordered slices are matched by equal elements and the rest is
+//compared by index, missing structs are reported field by field skipping zero values
+
+//subPath copies path with elems appended, so records never share backing arrays
+func subPath(path []string, elems ...string) []string {
+	dst := make([]string, len(path)+len(elems))
+	copy(dst, path)
+	copy(dst[len(path):], elems)
+	return dst
+}
+
+func compareString(cl *diff.Changelog, path []string, field string, a string, b string) {
+	if a != b {
+		cl.Add(diff.UPDATE, subPath(path, field), a, b)
+	}
+}
+
+func compareTimestamp(cl *diff.Changelog, path []string, field string, a *Timestamp, b *Timestamp) {
+	if a.UnixNano() != b.UnixNano() {
+		cl.Add(diff.UPDATE, subPath(path, field, "Time"), a.Time, b.Time)
+	}
+}
+
+//unmatched returns indexes of slice elements which have no equal pair in the other slice.
+//Indexes of a go first, the same index of a and b is compared as a pair
+func unmatched(lenA int, lenB int, equal func(i int, j int) bool) (keys []int, inA map[int]bool, inB map[int]bool) {
+	if lenA == lenB {
+		same := true
+		for i := 0; i < lenA && same; i++ {
+			same = equal(i, i)
+		}
+		if same {
+			return
+		}
+	}
+
+	inA, inB = make(map[int]bool), make(map[int]bool)
+	matchedB := make([]bool, lenB)
+	for i := 0; i < lenA; i++ {
+		found := false
+		for j := 0; j < lenB && !found; j++ {
+			if !matchedB[j] && equal(i, j) {
+				matchedB[j], found = true, true
+			}
+		}
+		if !found {
+			inA[i] = true
+			keys = append(keys, i)
+		}
+	}
+
+	matchedA := make([]bool, lenA)
+	for j := 0; j < lenB; j++ {
+		found := false
+		for i := 0; i < lenA && !found; i++ {
+			if !matchedA[i] && equal(i, j) {
+				matchedA[i], found = true, true
+			}
+		}
+		if !found {
+			inB[j] = true
+			if !inA[j] {
+				keys = append(keys, j)
+			}
+		}
+	}
+	return
+}
+
+func equalFlights(a *Flight, b *Flight) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Carrier == b.Carrier &&
+		a.FlightNumber == b.FlightNumber &&
+		a.Source == b.Source &&
+		a.Destination == b.Destination &&
+		a.DepartureTimeStamp.Equal(b.DepartureTimeStamp.Time) &&
+		a.ArrivalTimeStamp.Equal(b.ArrivalTimeStamp.Time) &&
+		a.Class == b.Class &&
+		a.NumberOfStops == b.NumberOfStops &&
+		a.FareBasis == b.FareBasis &&
+		a.WarningText == b.WarningText &&
+		a.TicketType == b.TicketType
+}
+
+func equalPricing(a *Pricing, b *Pricing) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Currency != b.Currency || len(a.ServiceCharges) != len(b.ServiceCharges) {
+		return false
+	}
+	for idx := range a.ServiceCharges {
+		if a.ServiceCharges[idx] != b.ServiceCharges[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalFlightItems(a *FlightItem, b *FlightItem) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return equalFlights(a.Flight, b.Flight) && equalPricing(a.Pricing, b.Pricing)
+}
+
+func compareFlights(cl *diff.Changelog, path []string, a *Flight, b *Flight) {
+	carrier := subPath(path, "carrier")
+	compareString(cl, carrier, "name", a.Carrier.Name, b.Carrier.Name)
+	compareString(cl, carrier, "id", a.Carrier.ID, b.Carrier.ID)
+	compareString(cl, path, "flightNumber", a.FlightNumber, b.FlightNumber)
+	compareString(cl, path, "source", a.Source, b.Source)
+	compareString(cl, path, "destination", a.Destination, b.Destination)
+	compareTimestamp(cl, path, "departureTimeStamp", &a.DepartureTimeStamp, &b.DepartureTimeStamp)
+	compareTimestamp(cl, path, "arrivalTimeStamp", &a.ArrivalTimeStamp, &b.ArrivalTimeStamp)
+	compareString(cl, path, "class", a.Class, b.Class)
+	if a.NumberOfStops != b.NumberOfStops {
+		cl.Add(diff.UPDATE, subPath(path, "numberOfStops"), a.NumberOfStops, b.NumberOfStops)
+	}
+	compareString(cl, path, "fareBasis", a.FareBasis, b.FareBasis)
+	compareString(cl, path, "warningText", a.WarningText, b.WarningText)
+	compareString(cl, path, "ticketType", a.TicketType, b.TicketType)
+}
+
+//flightValues reports non zero fields of a missing flight as created or deleted
+func flightValues(cl *diff.Changelog, t string, path []string, f *Flight) {
+	var zero Flight
+	var values diff.Changelog
+	compareFlights(&values, path, &zero, f)
+	for _, change := range values {
+		if t == diff.CREATE {
+			cl.Add(t, change.Path, nil, change.To)
+		} else {
+			cl.Add(t, change.Path, change.To, nil)
+		}
+	}
+}
+
+func compareServiceCharges(cl *diff.Changelog, path []string, a []ServiceCharge, b []ServiceCharge) {
+	keys, inA, inB := unmatched(len(a), len(b), func(i int, j int) bool { return a[i] == b[j] })
+	for _, key := range keys {
+		elemPath := subPath(path, strconv.Itoa(key))
+		switch {
+		case inA[key] && inB[key]:
+			if a[key].Amount != b[key].Amount {
+				cl.Add(diff.UPDATE, subPath(elemPath, "amount"), a[key].Amount, b[key].Amount)
+			}
+			compareString(cl, elemPath, "type", a[key].Type, b[key].Type)
+			compareString(cl, elemPath, "chargeType", a[key].ChargeType, b[key].ChargeType)
+		case inA[key]:
+			serviceChargeValues(cl, diff.DELETE, elemPath, &a[key])
+		default:
+			serviceChargeValues(cl, diff.CREATE, elemPath, &b[key])
+		}
+	}
+}
+
+func serviceChargeValues(cl *diff.Changelog, t string, path []string, c *ServiceCharge) {
+	add := func(field string, value interface{}) {
+		if t == diff.CREATE {
+			cl.Add(t, subPath(path, field), nil, value)
+		} else {
+			cl.Add(t, subPath(path, field), value, nil)
+		}
+	}
+	if c.Amount != 0 {
+		add("amount", c.Amount)
+	}
+	if c.Type != "" {
+		add("type", c.Type)
+	}
+	if c.ChargeType != "" {
+		add("chargeType", c.ChargeType)
+	}
+}
+
+func comparePricing(cl *diff.Changelog, path []string, a *Pricing, b *Pricing) {
+	compareString(cl, path, "currency", a.Currency, b.Currency)
+	compareServiceCharges(cl, subPath(path, "serviceCharges"), a.ServiceCharges, b.ServiceCharges)
+}
+
+func compareFlightItems(cl *diff.Changelog, path []string, a *FlightItem, b *FlightItem) {
+	flight := subPath(path, "flight")
+	switch {
+	case a.Flight == nil && b.Flight == nil:
+	case a.Flight == nil || b.Flight == nil:
+		cl.Add(diff.UPDATE, flight, nullable(a.Flight), nullable(b.Flight))
+	default:
+		compareFlights(cl, flight, a.Flight, b.Flight)
+	}
+
+	pricing := subPath(path, "pricing")
+	switch {
+	case a.Pricing == nil && b.Pricing == nil:
+	case a.Pricing == nil || b.Pricing == nil:
+		cl.Add(diff.UPDATE, pricing, nullable(a.Pricing), nullable(b.Pricing))
+	default:
+		comparePricing(cl, pricing, a.Pricing, b.Pricing)
+	}
+}
+
+//nullable converts typed nil pointers to untyped nil as diff.Diff reports them
+func nullable(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *Flight:
+		if v == nil {
+			return nil
+		}
+	case *Pricing:
+		if v == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+//flightItemValues reports pointers of a missing FlightItem as created or deleted
+func flightItemValues(cl *diff.Changelog, t string, path []string, item *FlightItem) {
+	add := func(field string, value interface{}) {
+		if t == diff.CREATE {
+			cl.Add(t, subPath(path, field), nil, value)
+		} else {
+			cl.Add(t, subPath(path, field), value, nil)
+		}
+	}
+	if item.Flight != nil {
+		add("flight", item.Flight)
+	}
+	if item.Pricing != nil {
+		add("pricing", item.Pricing)
+	}
+}
+
+//DiffFlightItems compares FlightItem a to b
+func DiffFlightItems(a *FlightItem, b *FlightItem) diff.Changelog {
+	var cl diff.Changelog
+	compareFlightItems(&cl, nil, a, b)
+	return cl
+}
+
+//DiffRoutes compares Route a to b
+func DiffRoutes(a *Route, b *Route) diff.Changelog {
+	var cl diff.Changelog
+	path := []string{"flights"}
+	keys, inA, inB := unmatched(len(a.Flights), len(b.Flights), func(i int, j int) bool {
+		return equalFlightItems(a.Flights[i], b.Flights[j])
+	})
+	for _, key := range keys {
+		elemPath := subPath(path, strconv.Itoa(key))
+		switch {
+		case inA[key] && inB[key]:
+			itemA, itemB := a.Flights[key], b.Flights[key]
+			switch {
+			case itemA == nil || itemB == nil:
+				cl.Add(diff.UPDATE, elemPath, nullableItem(itemA), nullableItem(itemB))
+			default:
+				compareFlightItems(&cl, elemPath, itemA, itemB)
+			}
+		case inA[key]:
+			if a.Flights[key] != nil {
+				flightItemValues(&cl, diff.DELETE, elemPath, a.Flights[key])
+			}
+		default:
+			if b.Flights[key] != nil {
+				flightItemValues(&cl, diff.CREATE, elemPath, b.Flights[key])
+			}
+		}
+	}
+	return cl
+}
+
+func nullableItem(item *FlightItem) interface{} {
+	if item == nil {
+		return nil
+	}
+	return item
+}
+
+func compareLegs(cl *diff.Changelog, path []string, a []*Flight, b []*Flight) {
+	keys, inA, inB := unmatched(len(a), len(b), func(i int, j int) bool { return equalFlights(a[i], b[j]) })
+	for _, key := range keys {
+		elemPath := subPath(path, strconv.Itoa(key))
+		switch {
+		case inA[key] && inB[key]:
+			switch {
+			case a[key] == nil || b[key] == nil:
+				cl.Add(diff.UPDATE, elemPath, nullable(a[key]), nullable(b[key]))
+			default:
+				compareFlights(cl, elemPath, a[key], b[key])
+			}
+		case inA[key]:
+			if a[key] != nil {
+				flightValues(cl, diff.DELETE, elemPath, a[key])
+			}
+		default:
+			if b[key] != nil {
+				flightValues(cl, diff.CREATE, elemPath, b[key])
+			}
+		}
+	}
+}
+
+//diffBundle compares Bundle a to b
+func diffBundle(a *Bundle, b *Bundle) diff.Changelog {
+	var cl diff.Changelog
+	compareString(&cl, nil, "Key", a.Key, b.Key)
+	compareLegs(&cl, []string{"onward"}, a.Onward, b.Onward)
+	compareLegs(&cl, []string{"return"}, a.Return, b.Return)
+	switch {
+	case a.Pricing == nil && b.Pricing == nil:
+	case a.Pricing == nil || b.Pricing == nil:
+		cl.Add(diff.UPDATE, []string{"pricing"}, nullable(a.Pricing), nullable(b.Pricing))
+	default:
+		comparePricing(&cl, []string{"pricing"}, a.Pricing, b.Pricing)
+	}
+	return cl
+}
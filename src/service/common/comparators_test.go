@@ -0,0 +1,142 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/r3labs/diff"
+)
+
+//testItem returns flight DXB-BKK of number priced at price with base fare and taxes
+func testItem(number string, price float32) *FlightItem {
+	departure := time.Date(2018, 10, 22, 0, 5, 0, 0, time.UTC)
+	f := &Flight{
+		FlightNumber:       number,
+		Source:             "DXB",
+		Destination:        "BKK",
+		DepartureTimeStamp: Timestamp{departure},
+		ArrivalTimeStamp:   Timestamp{departure.Add(10 * time.Hour)},
+		Class:              "G",
+		FareBasis:          "GLOW",
+		TicketType:         "E",
+	}
+	f.Carrier.ID, f.Carrier.Name = "AI", "AirIndia"
+	return &FlightItem{
+		Flight: f,
+		Pricing: &Pricing{Currency: "SGD", ServiceCharges: []ServiceCharge{
+			{Amount: price - 100, Type: "SingleAdult", ChargeType: "BaseFare"},
+			{Amount: 100, Type: "SingleAdult", ChargeType: "AirlineTaxes"},
+			{Amount: price, Type: "SingleAdult", ChargeType: "TotalAmount"},
+		}},
+	}
+}
+
+func TestDiffFlightItemsMatchesReflection(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(item *FlightItem)
+	}{
+		{"same", func(item *FlightItem) {}},
+		{"price", func(item *FlightItem) { item.Pricing.ServiceCharges[2].Amount += 50 }},
+		{"retimed", func(item *FlightItem) {
+			item.Flight.DepartureTimeStamp.Time = item.Flight.DepartureTimeStamp.Add(30 * time.Minute)
+			item.Flight.ArrivalTimeStamp.Time = item.Flight.ArrivalTimeStamp.Add(30 * time.Minute)
+		}},
+		{"class and carrier", func(item *FlightItem) { item.Flight.Class, item.Flight.Carrier.Name = "Y", "Air India" }},
+		{"stops", func(item *FlightItem) { item.Flight.NumberOfStops = 1 }},
+		{"dropped charges", func(item *FlightItem) { item.Pricing.ServiceCharges = item.Pricing.ServiceCharges[2:] }},
+		{"added charge", func(item *FlightItem) {
+			item.Pricing.ServiceCharges = append(item.Pricing.ServiceCharges, ServiceCharge{Amount: 5, Type: "SingleAdult", ChargeType: "Fee"})
+		}},
+		{"reordered charges", func(item *FlightItem) {
+			charges := item.Pricing.ServiceCharges
+			charges[0], charges[2] = charges[2], charges[0]
+		}},
+		{"no charges", func(item *FlightItem) { item.Pricing.ServiceCharges = nil }},
+		{"currency", func(item *FlightItem) { item.Pricing.Currency = "USD" }},
+		{"no pricing", func(item *FlightItem) { item.Pricing = nil }},
+		{"no flight", func(item *FlightItem) { item.Flight = nil }},
+	}
+	for _, test := range tests {
+		a, b := testItem("996", 500), testItem("996", 500)
+		test.mutate(b)
+
+		for _, pair := range [][2]*FlightItem{{a, b}, {b, a}} {
+			want, err := diff.Diff(*pair[0], *pair[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DiffFlightItems(pair[0], pair[1]); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: changelog %+v, want %+v", test.name, got, want)
+			}
+		}
+	}
+}
+
+func TestDiffRoutesMatchesReflection(t *testing.T) {
+	route := func(items ...*FlightItem) *Route {
+		return &Route{Flights: items}
+	}
+	repriced := testItem("997", 400)
+	repriced.Pricing.ServiceCharges[2].Amount = 450
+
+	tests := []struct {
+		name string
+		a    *Route
+		b    *Route
+	}{
+		{"same", route(testItem("996", 500), testItem("997", 400)), route(testItem("996", 500), testItem("997", 400))},
+		{"repriced flight", route(testItem("996", 500), testItem("997", 400)), route(testItem("996", 500), repriced)},
+		{"reordered flights", route(testItem("996", 500), testItem("997", 400)), route(testItem("997", 400), testItem("996", 500))},
+		{"replaced flight", route(testItem("996", 500), testItem("997", 400)), route(testItem("996", 500), testItem("998", 400))},
+		{"added flight", route(testItem("996", 500)), route(testItem("996", 500), testItem("997", 400))},
+		{"removed flights", route(testItem("996", 500), testItem("997", 400)), route()},
+		{"missing flight", route(testItem("996", 500), testItem("997", 400)), route(testItem("996", 500), nil)},
+	}
+	for _, test := range tests {
+		for _, pair := range [][2]*Route{{test.a, test.b}, {test.b, test.a}} {
+			want, err := diff.Diff(*pair[0], *pair[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DiffRoutes(pair[0], pair[1]); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: changelog %+v, want %+v", test.name, got, want)
+			}
+		}
+	}
+}
+
+func TestDiffBundleMatchesReflection(t *testing.T) {
+	bundle := func(key string, price float32, onward ...*FlightItem) *Bundle {
+		b := &Bundle{Key: key, Pricing: testItem("", price).Pricing}
+		for _, item := range onward {
+			b.Onward = append(b.Onward, item.Flight)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		a    *Bundle
+		b    *Bundle
+	}{
+		{"same", bundle("k", 500, testItem("996", 0)), bundle("k", 500, testItem("996", 0))},
+		{"repriced", bundle("k", 500, testItem("996", 0)), bundle("k", 550, testItem("996", 0))},
+		{"rekeyed", bundle("k", 500, testItem("996", 0)), bundle("k#1", 500, testItem("996", 0))},
+		{"replaced leg", bundle("k", 500, testItem("996", 0), testItem("997", 0)), bundle("k", 500, testItem("996", 0), testItem("998", 0))},
+		{"added leg", bundle("k", 500, testItem("996", 0)), bundle("k", 500, testItem("996", 0), testItem("997", 0))},
+		{"no pricing", bundle("k", 500, testItem("996", 0)), &Bundle{Key: "k", Onward: []*Flight{testItem("996", 0).Flight}}},
+	}
+	for _, test := range tests {
+		for _, pair := range [][2]*Bundle{{test.a, test.b}, {test.b, test.a}} {
+			want, err := diff.Diff(*pair[0], *pair[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diffBundle(pair[0], pair[1]); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: changelog %+v, want %+v", test.name, got, want)
+			}
+		}
+	}
+}
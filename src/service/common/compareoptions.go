@@ -0,0 +1,164 @@
+package common
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/r3labs/diff"
+)
+
+//CompareOptions relax change detection of Diff
+type CompareOptions struct {
+	PriceTolerance        float32  `json:"priceTolerance"`        //absolute amount difference to ignore
+	PriceTolerancePercent float32  `json:"priceTolerancePercent"` //relative amount difference to ignore
+	IgnoreFields          []string `json:"ignoreFields"`          //dotted paths without indices, i.e. "flight.warningText" or "warningText"
+	IgnoreCase            bool     `json:"ignoreCase"`
+	IgnoreWhitespace      bool     `json:"ignoreWhitespace"`
+}
+
+var (
+	defaultCompareOptions     CompareOptions
+	defaultCompareOptionsOnce sync.Once
+)
+
+//DefaultCompareOptions returns server defaults configured by env COMPARE_PRICE_TOLERANCE, COMPARE_PRICE_TOLERANCE_PERCENT,
+//COMPARE_IGNORE_FIELDS (comma separated), COMPARE_IGNORE_CASE and COMPARE_IGNORE_WHITESPACE
+func DefaultCompareOptions() CompareOptions {
+	defaultCompareOptionsOnce.Do(func() {
+		if v, err := strconv.ParseFloat(os.Getenv("COMPARE_PRICE_TOLERANCE"), 32); err == nil {
+			defaultCompareOptions.PriceTolerance = float32(v)
+		}
+		if v, err := strconv.ParseFloat(os.Getenv("COMPARE_PRICE_TOLERANCE_PERCENT"), 32); err == nil {
+			defaultCompareOptions.PriceTolerancePercent = float32(v)
+		}
+		defaultCompareOptions.IgnoreFields = splitFields(os.Getenv("COMPARE_IGNORE_FIELDS"))
+		defaultCompareOptions.IgnoreCase, _ = strconv.ParseBool(os.Getenv("COMPARE_IGNORE_CASE"))
+		defaultCompareOptions.IgnoreWhitespace, _ = strconv.ParseBool(os.Getenv("COMPARE_IGNORE_WHITESPACE"))
+	})
+
+	options := defaultCompareOptions
+	options.IgnoreFields = append([]string(nil), defaultCompareOptions.IgnoreFields...)
+	return options
+}
+
+//CompareOptionsRequest is a multipart/form-data binding of CompareOptions. Unset fields keep server defaults
+type CompareOptionsRequest struct {
+	PriceTolerance        *float32 `form:"price_tolerance"`
+	PriceTolerancePercent *float32 `form:"price_tolerance_percent"`
+	IgnoreFields          string   `form:"ignore_fields"` //comma separated, added to server defaults
+	IgnoreCase            *bool    `form:"ignore_case"`
+	IgnoreWhitespace      *bool    `form:"ignore_whitespace"`
+}
+
+//Options returns server defaults overridden by request
+func (r *CompareOptionsRequest) Options() *CompareOptions {
+	options := DefaultCompareOptions()
+	if r.PriceTolerance != nil {
+		options.PriceTolerance = *r.PriceTolerance
+	}
+	if r.PriceTolerancePercent != nil {
+		options.PriceTolerancePercent = *r.PriceTolerancePercent
+	}
+	options.IgnoreFields = append(options.IgnoreFields, splitFields(r.IgnoreFields)...)
+	if r.IgnoreCase != nil {
+		options.IgnoreCase = *r.IgnoreCase
+	}
+	if r.IgnoreWhitespace != nil {
+		options.IgnoreWhitespace = *r.IgnoreWhitespace
+	}
+	return &options
+}
+
+func splitFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+//Filter drops changes ignored by options. Nil options keep changelog as is
+func (o *CompareOptions) Filter(changelog diff.Changelog) diff.Changelog {
+	if o == nil || len(changelog) == 0 {
+		return changelog
+	}
+
+	var filtered diff.Changelog
+	for _, change := range changelog {
+		if !o.ignores(change) {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+func (o *CompareOptions) ignores(change diff.Change) bool {
+	field := "." + normalizePath(change.Path) + "."
+	for _, ignored := range o.IgnoreFields {
+		if strings.Contains(field, "."+ignored+".") {
+			return true
+		}
+	}
+
+	if change.Type != diff.UPDATE {
+		return false
+	}
+
+	switch from := change.From.(type) {
+	case float32:
+		to, ok := change.To.(float32)
+		return ok && o.withinPriceTolerance(from, to)
+	case string:
+		to, ok := change.To.(string)
+		return ok && o.equalStrings(from, to)
+	}
+	return false
+}
+
+func (o *CompareOptions) withinPriceTolerance(from float32, to float32) bool {
+	delta := to - from
+	if delta < 0 {
+		delta = -delta
+	}
+	if o.PriceTolerance > 0 && delta <= o.PriceTolerance {
+		return true
+	}
+	if o.PriceTolerancePercent > 0 && from != 0 {
+		base := from
+		if base < 0 {
+			base = -base
+		}
+		return delta/base*100 <= o.PriceTolerancePercent
+	}
+	return false
+}
+
+func (o *CompareOptions) equalStrings(a string, b string) bool {
+	if o.IgnoreWhitespace {
+		a = strings.Join(strings.Fields(a), " ")
+		b = strings.Join(strings.Fields(b), " ")
+	}
+	if o.IgnoreCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+//normalizePath joins changelog path without slice indices and route "flights" prefix
+func normalizePath(path []string) string {
+	var parts []string
+	for _, p := range path {
+		if _, err := strconv.Atoi(p); err == nil {
+			continue
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) > 0 && parts[0] == "flights" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ".")
+}
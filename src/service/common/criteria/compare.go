@@ -0,0 +1,160 @@
+package criteria
+
+import (
+	"sort"
+	"time"
+
+	"service/common"
+	"service/common/graph"
+)
+
+//Reasons of winner change between two snapshots
+const (
+	ReasonUnchanged       = "unchanged"
+	ReasonPriceChanged    = "price_changed"
+	ReasonScheduleChanged = "schedule_changed"
+	ReasonRemoved         = "removed"
+	ReasonOutperformed    = "outperformed"
+	ReasonNoRoute         = "no_route"
+)
+
+//Outcome is a comparison of criterion winners found in two snapshots
+type Outcome struct {
+	Old      []common.Route `json:"old"`
+	New      []common.Route `json:"new"`
+	OldValue *float64       `json:"oldValue"`
+	NewValue *float64       `json:"newValue"`
+	Delta    *float64       `json:"delta"`
+	Changed  bool           `json:"changed"`
+	Reason   string         `json:"reason"`
+}
+
+//value converts criterion value to number, durations are measured in minutes
+func value(v interface{}) *float64 {
+	var f float64
+	switch v := v.(type) {
+	case float32:
+		f = float64(v)
+	case time.Duration:
+		f = v.Minutes()
+	default:
+		return nil
+	}
+	return &f
+}
+
+func routeKeys(paths []*graph.Path) []string {
+	var keys []string
+	for _, p := range paths {
+		route := common.NewRoute(p)
+		keys = append(keys, route.Key())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sameKeys(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func samePricing(a []graph.Edge, b []graph.Edge) bool {
+	for idx := range a {
+		priceA, okA := a[idx].(*common.FlightItem).Pricing.GetTotalAmount()
+		priceB, okB := b[idx].(*common.FlightItem).Pricing.GetTotalAmount()
+		if okA != okB || priceA != priceB {
+			return false
+		}
+	}
+	return true
+}
+
+//rebuild finds path flights in flights, returns nil if any flight is gone or connection is broken
+func rebuild(path *graph.Path, flights *common.FlightsList) *graph.Path {
+	var values []graph.Edge
+	for idx, e := range path.Edges() {
+		item, ok := flights.Get(e.(*common.FlightItem).Flight.Key())
+		if !ok {
+			return nil
+		}
+		if idx > 0 && !item.IsAccessibleFrom(values[idx-1]) {
+			return nil
+		}
+		values = append(values, &item)
+	}
+	return graph.NewPath(values...)
+}
+
+//explain detects why winner of criterion found by fn changed from pathA to a path of snapshot B
+func explain(fn func(c *Criterion, path *graph.Path) (interface{}, bool), pathA *graph.Path, flightsB *common.FlightsList) string {
+	pathB := rebuild(pathA, flightsB)
+	if pathB == nil {
+		return ReasonRemoved
+	}
+
+	valueA, _ := fn(&Criterion{}, pathA)
+	valueB, _ := fn(&Criterion{}, pathB)
+	if valueA == valueB {
+		return ReasonOutperformed
+	}
+	if !samePricing(pathA.Edges(), pathB.Edges()) {
+		return ReasonPriceChanged
+	}
+	return ReasonScheduleChanged
+}
+
+//Compare compares winners of criteria searched in setA and setB. flightsB is a snapshot B used
+//to find out if the old winner is still available
+func Compare(setA Set, setB Set, flightsB *common.FlightsList) map[string]*Outcome {
+	outcomes := make(map[string]*Outcome)
+	for key, criterionA := range setA {
+		criterionB, ok := setB[key]
+		if !ok {
+			continue
+		}
+
+		outcome := &Outcome{}
+		for _, p := range criterionA.GetResult() {
+			outcome.Old = append(outcome.Old, common.NewRoute(p))
+		}
+		for _, p := range criterionB.GetResult() {
+			outcome.New = append(outcome.New, common.NewRoute(p))
+		}
+		if criterionA.hasValue {
+			outcome.OldValue = value(criterionA.Value)
+		}
+		if criterionB.hasValue {
+			outcome.NewValue = value(criterionB.Value)
+		}
+		if outcome.OldValue != nil && outcome.NewValue != nil {
+			delta := *outcome.NewValue - *outcome.OldValue
+			outcome.Delta = &delta
+		}
+
+		pathsA, pathsB := criterionA.GetResult(), criterionB.GetResult()
+		switch {
+		case len(pathsA) == 0 && len(pathsB) == 0:
+			outcome.Reason = ReasonNoRoute
+		case len(pathsA) == 0:
+			outcome.Changed = true
+			outcome.Reason = ReasonOutperformed
+		case sameKeys(routeKeys(pathsA), routeKeys(pathsB)):
+			outcome.Reason = ReasonUnchanged
+			if outcome.Delta != nil && *outcome.Delta != 0 {
+				outcome.Reason = explain(criterionA.Fn, pathsA[0], flightsB)
+			}
+		default:
+			outcome.Changed = true
+			outcome.Reason = explain(criterionA.Fn, pathsA[0], flightsB)
+		}
+		outcomes[key] = outcome
+	}
+	return outcomes
+}
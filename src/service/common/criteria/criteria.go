@@ -1,8 +1,11 @@
-package handlers
+package criteria
 
 import (
+	"context"
+	"fmt"
 	"service/common"
 	"service/common/graph"
+	"strings"
 	"time"
 )
 
@@ -12,6 +15,8 @@ type Criterion struct {
 	hasValue bool
 	Value    interface{}
 	Fn       func(c *Criterion, path *graph.Path) (interface{}, bool)
+
+	fastest bool //minimizes time from the first departure to the last arrival
 }
 
 func (c *Criterion) GetResult() []*graph.Path {
@@ -74,6 +79,7 @@ func NewMaximumCostCriterion() *Criterion {
 //NewMinimumTimeCriterion returns criterion which minifies route time
 func NewMinimumTimeCriterion() *Criterion {
 	return &Criterion{
+		fastest: true,
 		Fn: func(c *Criterion, path *graph.Path) (interface{}, bool) {
 			edges := path.Edges()
 
@@ -145,3 +151,79 @@ func NewOptimalCriterion(weights *OptimalCriterionWeights) *Criterion {
 		},
 	}
 }
+
+//DefaultOptimalCriterionWeights weights used by rank endpoints
+var DefaultOptimalCriterionWeights = OptimalCriterionWeights{
+	Time:            2,
+	Cost:            1,
+	NumberOfFlights: 3,
+}
+
+//Set is a named collection of criteria applied in a single search
+type Set map[string]*Criterion
+
+//NewDefaultSet returns criteria used by rank endpoints
+func NewDefaultSet() Set {
+	weights := DefaultOptimalCriterionWeights
+	return Set{
+		"minCost": NewMinimumCostCriterion(),
+		"maxCost": NewMaximumCostCriterion(),
+		"minTime": NewMinimumTimeCriterion(),
+		"maxTime": NewMaximumTimeCriterion(),
+		"optimal": NewOptimalCriterion(&weights),
+	}
+}
+
+//NewSet returns criteria of default set by comma separated names, empty names select the whole default set
+func NewSet(names string) (Set, error) {
+	all := NewDefaultSet()
+	if strings.TrimSpace(names) == "" {
+		return all, nil
+	}
+
+	s := Set{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		criterion, exist := all[name]
+		if !exist {
+			return nil, fmt.Errorf("unknown criterion %q", name)
+		}
+		s[name] = criterion
+	}
+	return s, nil
+}
+
+//Search applies set criteria to paths between two nodes. Set of minimum time criteria only is served by
+//A* search which doesn't enumerate slower paths, other sets by SearchOptimalPathsContext
+func (s Set) Search(ctx context.Context, g *graph.Graph, from string, to string, limit int, budget *graph.Budget) (graph.SearchStats, error) {
+	fastest := len(s) > 0
+	for _, criterion := range s {
+		fastest = fastest && criterion.fastest
+	}
+	if !fastest {
+		return g.SearchOptimalPathsContext(ctx, from, to, limit, budget, s.List()...)
+	}
+
+	paths, stats, err := g.SearchFastestPaths(ctx, from, to, limit, budget)
+	if err == graph.ErrUntimedGraph {
+		return g.SearchOptimalPathsContext(ctx, from, to, limit, budget, s.List()...)
+	}
+	if err != nil {
+		return stats, err
+	}
+	for idx := range paths {
+		for _, criterion := range s {
+			criterion.Apply(&paths[idx])
+		}
+	}
+	return stats, nil
+}
+
+//List returns set criteria as graph.OptimalCriterion slice
+func (s Set) List() []graph.OptimalCriterion {
+	var list []graph.OptimalCriterion
+	for _, criterion := range s {
+		list = append(list, criterion)
+	}
+	return list
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"service/common/store"
+)
+
+//ErrNoData is returned when request has neither uploaded file nor dataset id
+var ErrNoData = errors.New("either data file or dataset id required")
+
+//ReadUpload reads uploaded file content
+func ReadUpload(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, &InputError{Code: CodeUnreadableUpload, Message: err.Error()}
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, &InputError{Code: CodeUnreadableUpload, Message: err.Error()}
+	}
+	return content, nil
+}
+
+//ParseAirFareSearchResponse unmarshals xml content into AirFareSearchResponse. Malformed or
+//inconsistent content is rejected with InputError or InputErrors
+func ParseAirFareSearchResponse(content []byte) (*AirFareSearchResponse, error) {
+	if err := checkRoot(content); err != nil {
+		return nil, err
+	}
+
+	var data AirFareSearchResponse
+	if err := xml.Unmarshal(content, &data); err != nil {
+		return nil, decodeError(err, content)
+	}
+	if err := validateAirFareSearchResponse(&data, content); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+//ReadAirFareSearchResponse reads uploaded xml file into AirFareSearchResponse
+func ReadAirFareSearchResponse(header *multipart.FileHeader) (*AirFareSearchResponse, error) {
+	content, err := ReadUpload(header)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAirFareSearchResponse(content)
+}
+
+//LoadDataset reads AirFareSearchResponse from dataset store
+func LoadDataset(id string) (*AirFareSearchResponse, error) {
+	s, err := store.Default()
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAirFareSearchResponse(content)
+}
+
+//LoadData reads AirFareSearchResponse from uploaded file or, if there is no file, from dataset store
+func LoadData(header *multipart.FileHeader, datasetID string) (*AirFareSearchResponse, error) {
+	if header != nil {
+		return ReadAirFareSearchResponse(header)
+	}
+	if datasetID != "" {
+		return LoadDataset(datasetID)
+	}
+	return nil, ErrNoData
+}
+
+//LoadContent reads uploaded file or, if there is no file, dataset content without parsing it
+func LoadContent(header *multipart.FileHeader, datasetID string) ([]byte, error) {
+	if header != nil {
+		return ReadUpload(header)
+	}
+	if datasetID != "" {
+		s, err := store.Default()
+		if err != nil {
+			return nil, err
+		}
+		return s.Get(datasetID)
+	}
+	return nil, ErrNoData
+}
+
+//OpenData opens uploaded file or, if there is no file, dataset content for streaming reads
+func OpenData(header *multipart.FileHeader, datasetID string) (io.ReadCloser, error) {
+	if header != nil {
+		file, err := header.Open()
+		if err != nil {
+			return nil, &InputError{Code: CodeUnreadableUpload, Message: err.Error()}
+		}
+		return file, nil
+	}
+	if datasetID != "" {
+		s, err := store.Default()
+		if err != nil {
+			return nil, err
+		}
+		return s.Reader(datasetID)
+	}
+	return nil, ErrNoData
+}
+
+//LoadDataStatus returns http status for LoadData error: 400 for rejected input, 404 for unknown dataset and
+//500 for store and other failures
+func LoadDataStatus(err error) int {
+	switch err.(type) {
+	case *InputError, InputErrors:
+		return http.StatusBadRequest
+	}
+	switch err {
+	case ErrNoData, store.ErrInvalidID:
+		return http.StatusBadRequest
+	case store.ErrNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+//DatasetInfo describes stored AirFareSearchResponse
+type DatasetInfo struct {
+	ID           string `json:"id"`
+	Size         int    `json:"size"`
+	RequestID    string `json:"requestId"`
+	RequestTime  string `json:"requestTime"`
+	ResponseTime string `json:"responseTime"`
+	Itineraries  int    `json:"itineraries"`
+	Flights      int    `json:"flights"`
+}
+
+//NewDatasetInfo creates DatasetInfo by stored content and its parsed data
+func NewDatasetInfo(id string, content []byte, data *AirFareSearchResponse) *DatasetInfo {
+	info := DatasetInfo{
+		ID:           id,
+		Size:         len(content),
+		RequestID:    data.RequestID,
+		RequestTime:  data.RequestTime,
+		ResponseTime: data.ResponseTime,
+		Itineraries:  len(data.PricedItineraries.Flights),
+	}
+	for _, f := range data.PricedItineraries.Flights {
+		info.Flights += len(f.OnwardPricedItinerary.Flights.Flight) + len(f.ReturnPricedItinerary.Flights.Flight)
+	}
+	return &info
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Duplicate key policies of FlightsList, RoutesList and bundles
+const (
+	DuplicatesOverwrite    = "overwrite"
+	DuplicatesKeepAll      = "keep_all"
+	DuplicatesKeepCheapest = "keep_cheapest"
+	DuplicatesFail         = "fail"
+)
+
+//ParseDuplicatePolicy validates duplicate key policy, empty policy keeps all duplicates so none of them is
+//dropped silently
+func ParseDuplicatePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return DuplicatesKeepAll, nil
+	case DuplicatesOverwrite, DuplicatesKeepAll, DuplicatesKeepCheapest, DuplicatesFail:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown duplicates policy %q", policy)
+}
+
+//DuplicateKeysError is returned by fail policy when items collide
+type DuplicateKeysError struct {
+	Keys []string
+}
+
+func (e *DuplicateKeysError) Error() string {
+	return fmt.Sprintf("duplicate keys: %s", strings.Join(e.Keys, ", "))
+}
+
+//FlightCollision is a set of flights sharing the same key
+type FlightCollision struct {
+	Key     string       `json:"key"`
+	Flights []FlightItem `json:"flights"`
+}
+
+//RouteCollision is a set of routes sharing the same key
+type RouteCollision struct {
+	Key    string  `json:"key"`
+	Routes []Route `json:"routes"`
+}
+
+//BundleCollision is a set of priced itineraries sharing the same legs
+type BundleCollision struct {
+	Key     string   `json:"key"`
+	Bundles []Bundle `json:"bundles"`
+}
+
+//duplicateKey disambiguates n-th duplicate of key, the first item keeps the original key
+func duplicateKey(key string, n int) string {
+	if n == 0 {
+		return key
+	}
+	return key + "#" + strconv.Itoa(n)
+}
+
+//resolveDuplicates groups items by keys and picks ones to keep due policy. Duplicates are ordered by
+//price, so keep_all pairs the cheapest offers of both lists first, overwrite keeps the last item. Returns kept items indexes by keys
+//and colliding groups sorted by key
+func resolveDuplicates(keys []string, price func(idx int) (float32, bool), policy string) (map[string]int, []string, map[string][]int, error) {
+	groups := make(map[string][]int)
+	for idx, key := range keys {
+		groups[key] = append(groups[key], idx)
+	}
+
+	var collisions []string
+	for key, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		collisions = append(collisions, key)
+		sort.SliceStable(group, func(i, j int) bool {
+			priceI, okI := price(group[i])
+			priceJ, okJ := price(group[j])
+			if okI != okJ {
+				return okI
+			}
+			return priceI < priceJ
+		})
+	}
+	sort.Strings(collisions)
+
+	if policy == DuplicatesFail && len(collisions) > 0 {
+		return nil, collisions, groups, &DuplicateKeysError{collisions}
+	}
+
+	kept := make(map[string]int)
+	for key, group := range groups {
+		switch policy {
+		case DuplicatesOverwrite:
+			last := group[0]
+			for _, idx := range group {
+				if idx > last {
+					last = idx
+				}
+			}
+			kept[key] = last
+			continue
+		case DuplicatesKeepCheapest:
+			kept[key] = group[0]
+			continue
+		}
+		for n, idx := range group {
+			kept[duplicateKey(key, n)] = idx
+		}
+	}
+	return kept, collisions, groups, nil
+}
@@ -0,0 +1,253 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/r3labs/diff"
+)
+
+//ChangeEvent types
+const (
+	PriceIncrease    = "price_increase"
+	PriceDecrease    = "price_decrease"
+	ChargeAdded      = "charge_added"
+	ChargeRemoved    = "charge_removed"
+	CurrencyChange   = "currency_change"
+	ClassChange      = "class_change"
+	StopsChange      = "stops_change"
+	TicketTypeChange = "ticket_type_change"
+	FareBasisChange  = "fare_basis_change"
+	DepartureChange  = "departure_change"
+	ArrivalChange    = "arrival_change"
+	WarningAdded     = "warning_added"
+	WarningRemoved   = "warning_removed"
+	WarningChanged   = "warning_changed"
+	FieldChange      = "field_change"
+)
+
+//Severity levels in ascending order
+const (
+	SeverityInfo     = "info"
+	SeverityMinor    = "minor"
+	SeverityMajor    = "major"
+	SeverityCritical = "critical"
+)
+
+var severityRanks = map[string]int{
+	SeverityInfo:     0,
+	SeverityMinor:    1,
+	SeverityMajor:    2,
+	SeverityCritical: 3,
+}
+
+//ParseSeverity validates severity level name. Empty name means the lowest level
+func ParseSeverity(severity string) (string, error) {
+	if severity == "" {
+		return SeverityInfo, nil
+	}
+	if _, exist := severityRanks[severity]; !exist {
+		return "", fmt.Errorf("unknown severity %q", severity)
+	}
+	return severity, nil
+}
+
+//ChangeEvent is a classified modification of a flight
+type ChangeEvent struct {
+	Type         string      `json:"type"`
+	Severity     string      `json:"severity"`
+	Field        string      `json:"field"`
+	Flight       string      `json:"flight,omitempty"` //flight key, set for route events
+	Old          interface{} `json:"old"`
+	New          interface{} `json:"new"`
+	Delta        *float32    `json:"delta,omitempty"`
+	DeltaPercent *float32    `json:"deltaPercent,omitempty"`
+
+	change int
+}
+
+//ChangeIndex returns index of the changelog entry event was classified from
+func (e *ChangeEvent) ChangeIndex() int {
+	return e.change
+}
+
+//AtLeast detects if event severity is not lower than given level
+func (e *ChangeEvent) AtLeast(severity string) bool {
+	return severityRanks[e.Severity] >= severityRanks[severity]
+}
+
+//ClassifyFlightChanges converts changelog of FlightItem into typed events
+func ClassifyFlightChanges(origin *FlightItem, changelog diff.Changelog) []ChangeEvent {
+	var events []ChangeEvent
+	for idx, change := range changelog {
+		event := classifyChange(origin, change.Type, change.Path, change.From, change.To)
+		event.change = idx
+		events = append(events, event)
+	}
+	return events
+}
+
+//ClassifyRouteChanges converts changelog of Route into typed events
+func ClassifyRouteChanges(origin *Route, changelog diff.Changelog) []ChangeEvent {
+	var events []ChangeEvent
+	for idx, change := range changelog {
+		var item *FlightItem
+		path := change.Path
+		if len(path) > 2 && path[0] == "flights" {
+			if idx, err := strconv.Atoi(path[1]); err == nil && idx < len(origin.Flights) {
+				item = origin.Flights[idx]
+				path = path[2:]
+			}
+		}
+		event := classifyChange(item, change.Type, path, change.From, change.To)
+		event.change = idx
+		if item != nil {
+			event.Flight = item.Flight.Key()
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func classifyChange(origin *FlightItem, changeType string, path []string, from interface{}, to interface{}) ChangeEvent {
+	event := ChangeEvent{
+		Type:     FieldChange,
+		Severity: SeverityInfo,
+		Field:    strings.Join(path, "."),
+		Old:      from,
+		New:      to,
+	}
+
+	if len(path) < 2 {
+		return event
+	}
+
+	switch path[0] {
+	case "flight":
+		switch path[1] {
+		case "class":
+			event.Type, event.Severity = ClassChange, SeverityMajor
+		case "numberOfStops":
+			event.Type, event.Severity = StopsChange, SeverityMajor
+		case "departureTimeStamp":
+			event.Type, event.Severity, event.Field = DepartureChange, SeverityMajor, "flight.departureTimeStamp"
+		case "arrivalTimeStamp":
+			event.Type, event.Severity, event.Field = ArrivalChange, SeverityMajor, "flight.arrivalTimeStamp"
+		case "ticketType":
+			event.Type, event.Severity = TicketTypeChange, SeverityMinor
+		case "fareBasis":
+			event.Type, event.Severity = FareBasisChange, SeverityMinor
+		case "warningText":
+			switch {
+			case from == "" || from == nil:
+				event.Type, event.Severity = WarningAdded, SeverityMinor
+			case to == "" || to == nil:
+				event.Type = WarningRemoved
+			default:
+				event.Type = WarningChanged
+			}
+		}
+
+	case "pricing":
+		if path[1] == "currency" {
+			event.Type, event.Severity = CurrencyChange, SeverityMajor
+			return event
+		}
+		if path[1] != "serviceCharges" || len(path) < 3 {
+			return event
+		}
+
+		chargeType := ""
+		if idx, err := strconv.Atoi(path[2]); err == nil && origin != nil && origin.Pricing != nil && idx < len(origin.Pricing.ServiceCharges) {
+			chargeType = origin.Pricing.ServiceCharges[idx].ChargeType
+		}
+		if chargeType != "" {
+			event.Field = "pricing." + chargeType
+		}
+
+		switch {
+		case changeType == diff.CREATE:
+			event.Type, event.Severity = ChargeAdded, SeverityMinor
+		case changeType == diff.DELETE:
+			event.Type, event.Severity = ChargeRemoved, SeverityMinor
+		case len(path) == 4 && path[3] == "amount":
+			classifyPrice(&event, chargeType == "TotalAmount")
+		}
+	}
+	return event
+}
+
+//classifyPrice sets price event type, deltas and severity. Only total amount moves are above info
+func classifyPrice(event *ChangeEvent, isTotal bool) {
+	from, okFrom := event.Old.(float32)
+	to, okTo := event.New.(float32)
+	if !okFrom || !okTo {
+		return
+	}
+
+	delta := to - from
+	event.Delta = &delta
+	event.Type = PriceIncrease
+	if delta < 0 {
+		event.Type = PriceDecrease
+	}
+
+	if from != 0 {
+		percent := delta / from * 100
+		event.DeltaPercent = &percent
+	}
+
+	if !isTotal {
+		return
+	}
+
+	event.Severity = SeverityMinor
+	if event.DeltaPercent != nil {
+		percent := *event.DeltaPercent
+		if percent < 0 {
+			percent = -percent
+		}
+		switch {
+		case percent >= 50:
+			event.Severity = SeverityCritical
+		case percent >= 10:
+			event.Severity = SeverityMajor
+		}
+	}
+}
+
+//FilterEvents returns events with severity not lower than given level
+func FilterEvents(events []ChangeEvent, severity string) []ChangeEvent {
+	var filtered []ChangeEvent
+	for _, event := range events {
+		if event.AtLeast(severity) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+//FilterFlightUpdates keeps events not lower than severity and drops updates left without events
+func FilterFlightUpdates(updates []FlightUpdate, severity string) []FlightUpdate {
+	var filtered []FlightUpdate
+	for _, update := range updates {
+		update.Events = FilterEvents(update.Events, severity)
+		if len(update.Events) > 0 {
+			filtered = append(filtered, update)
+		}
+	}
+	return filtered
+}
+
+//FilterRouteUpdates keeps events not lower than severity and drops updates left without events
+func FilterRouteUpdates(updates []RouteUpdate, severity string) []RouteUpdate {
+	var filtered []RouteUpdate
+	for _, update := range updates {
+		update.Events = FilterEvents(update.Events, severity)
+		if len(update.Events) > 0 {
+			filtered = append(filtered, update)
+		}
+	}
+	return filtered
+}
@@ -1,8 +1,6 @@
 package common
 
 import (
-	"sync"
-
 	"github.com/r3labs/diff"
 )
 
@@ -10,86 +8,122 @@ var WorkersCount int = 10
 
 type FlightsList struct {
 	flightItems map[string]FlightItem
+	collisions  []FlightCollision
 }
 
-//NewFlightsList creates FlightsList by data from AirFareSearchResponse
+//NewFlightsList creates FlightsList by data from AirFareSearchResponse keyed by Flight.Key
 func NewFlightsList(data *AirFareSearchResponse) *FlightsList {
+	return NewFlightsListWithProfile(data, &DefaultKeyProfile)
+}
+
+//NewFlightsListWithProfile creates FlightsList by data from AirFareSearchResponse keyed by profile.
+//Lists are compared by keys, so both sides of Diff should use the same profile. Duplicates are
+//overwritten by the last one
+func NewFlightsListWithProfile(data *AirFareSearchResponse, profile *KeyProfile) *FlightsList {
+	fl, _ := NewFlightsListWithPolicy(data, profile, DuplicatesOverwrite)
+	return fl
+}
+
+//NewFlightsListWithPolicy creates FlightsList by data from AirFareSearchResponse keyed by profile.
+//Flights sharing the same key are resolved due duplicates policy and reported as collisions
+func NewFlightsListWithPolicy(data *AirFareSearchResponse, profile *KeyProfile, policy string) (*FlightsList, error) {
+	var items []FlightItem
+	var keys []string
+	for p := range data.PricedItineraries.Flights {
+		f := &data.PricedItineraries.Flights[p]
+		for _, itinerary := range []*PricedItinerary{&f.OnwardPricedItinerary, &f.ReturnPricedItinerary} {
+			for idx := range itinerary.Flights.Flight {
+				items = append(items, FlightItem{
+					Flight:  &itinerary.Flights.Flight[idx],
+					Pricing: &f.Pricing,
+				})
+				keys = append(keys, profile.Key(&itinerary.Flights.Flight[idx]))
+			}
+		}
+	}
+	return newFlightsList(keys, items, policy)
+}
+
+//newFlightsList creates FlightsList by items and their keys resolving duplicates due policy
+func newFlightsList(keys []string, items []FlightItem, policy string) (*FlightsList, error) {
+	kept, collisions, groups, err := resolveDuplicates(keys, func(idx int) (float32, bool) {
+		return items[idx].Pricing.GetTotalAmount()
+	}, policy)
+
 	fl := FlightsList{
 		flightItems: make(map[string]FlightItem),
 	}
-	for p, f := range data.PricedItineraries.Flights {
-		for _, items := range []PricedItinerary{f.OnwardPricedItinerary, f.ReturnPricedItinerary} {
-
-			for idx, flight := range items.Flights.Flight {
-				fl.flightItems[flight.Key()] = FlightItem{
-					Flight:  &items.Flights.Flight[idx],
-					Pricing: &data.PricedItineraries.Flights[p].Pricing,
-				}
-			}
+	for _, key := range collisions {
+		collision := FlightCollision{Key: key}
+		for _, idx := range groups[key] {
+			collision.Flights = append(collision.Flights, items[idx])
 		}
+		fl.collisions = append(fl.collisions, collision)
 	}
+	if err != nil {
+		return &fl, err
+	}
+
+	for key, idx := range kept {
+		fl.flightItems[key] = items[idx]
+	}
+	return &fl, nil
+}
 
-	return &fl
+//Collisions returns flights sharing the same key
+func (fl *FlightsList) Collisions() []FlightCollision {
+	return fl.collisions
+}
+
+//Get returns FlightItem by key
+func (fl *FlightsList) Get(key string) (item FlightItem, ok bool) {
+	item, ok = fl.flightItems[key]
+	return
 }
 
 type FlightUpdate struct {
 	FlightItem FlightItem     `json:"origin"`
 	Changelog  diff.Changelog `json:"changes"`
+	Events     []ChangeEvent  `json:"events"`
 }
 
-func (flightsB *FlightsList) Diff(flightsA *FlightsList) (additions []FlightItem, removals []FlightItem, modifications []FlightUpdate) {
-
-	type comparePair struct {
-		flightA FlightItem
-		flightB FlightItem
+func (fl *FlightsList) keys() []string {
+	keys := make([]string, 0, len(fl.flightItems))
+	for key := range fl.flightItems {
+		keys = append(keys, key)
 	}
+	return keys
+}
 
-	jobs := make(chan comparePair)
-	updates := make(chan FlightUpdate)
-
-	wgUpdates := sync.WaitGroup{}
-	wgUpdates.Add(1)
-
-	go func(updates <-chan FlightUpdate) {
-		defer wgUpdates.Done()
-		for result := range updates {
-			modifications = append(modifications, result)
-		}
-	}(updates)
+func (fl *FlightsList) has(key string) bool {
+	_, ok := fl.flightItems[key]
+	return ok
+}
 
-	wgWorkers := sync.WaitGroup{}
-	wgWorkers.Add(WorkersCount)
+func (fl *FlightsList) compare(key string, other keyedList) diff.Changelog {
+	a, b := fl.flightItems[key], other.(*FlightsList).flightItems[key]
+	return DiffFlightItems(&a, &b)
+}
 
-	var worker = func(jobs <-chan comparePair, updates chan<- FlightUpdate) {
-		defer wgWorkers.Done()
-		for compare := range jobs {
-			changelog, _ := diff.Diff(compare.flightA, compare.flightB)
-			if len(changelog) > 0 {
-				updates <- FlightUpdate{compare.flightA, changelog}
-			}
-		}
-	}
+//Diff compares flightsA to flightsB. Changes ignored by options are dropped, nil options detect any change.
+//Resulting items carry keys they were matched by
+func (flightsB *FlightsList) Diff(flightsA *FlightsList, options *CompareOptions) (additions []FlightItem, removals []FlightItem, modifications []FlightUpdate) {
+	added, removed, changed := diffKeyed(flightsA, flightsB, WorkersCount, options)
 
-	for w := 0; w < WorkersCount; w++ {
-		go worker(jobs, updates)
+	for _, key := range added {
+		item := flightsB.flightItems[key]
+		item.MatchKey = key
+		additions = append(additions, item)
 	}
-
-	for _, flightA := range flightsA.flightItems {
-		if flightB, exist := flightsB.flightItems[flightA.Flight.Key()]; exist {
-			jobs <- comparePair{flightA, flightB}
-		} else {
-			removals = append(removals, flightA)
-		}
+	for _, key := range removed {
+		item := flightsA.flightItems[key]
+		item.MatchKey = key
+		removals = append(removals, item)
 	}
-	close(jobs)
-	wgWorkers.Wait()
-	close(updates)
-	wgUpdates.Wait()
-
-	for _, flightB := range flightsB.flightItems {
-		if _, exist := flightsA.flightItems[flightB.Flight.Key()]; !exist {
-			additions = append(additions, flightB)
-		}
+	for _, change := range changed {
+		flightA := flightsA.flightItems[change.key]
+		flightA.MatchKey = change.key
+		modifications = append(modifications, FlightUpdate{flightA, change.changelog, ClassifyFlightChanges(&flightA, change.changelog)})
 	}
 	return
 }
@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+//fastest is an OptimalCriterion keeping paths of the minimum travel time
+type fastest struct {
+	paths []*Path
+	best  time.Duration
+}
+
+func (f *fastest) Apply(path *Path) {
+	switch d := travelTime(path.edges); {
+	case f.paths == nil || d < f.best:
+		f.paths, f.best = []*Path{path}, d
+	case d == f.best:
+		f.paths = append(f.paths, path)
+	}
+}
+
+func (f *fastest) GetResult() []*Path {
+	return f.paths
+}
+
+//hubFixture generates graph of n flights from hub H to spokes airports and n flights back over two weeks
+func hubFixture(seed int64, spokes int, n int) *Graph {
+	rnd := rand.New(rand.NewSource(seed))
+	start := time.Date(2018, 10, 22, 0, 0, 0, 0, time.UTC)
+
+	g := NewGraph(spokes + 1)
+	for id := 0; id < 2*n; id++ {
+		from, to := "H", fmt.Sprintf("S%02d", rnd.Intn(spokes))
+		if id%2 == 1 {
+			from, to = to, from
+		}
+		departure := start.Add(time.Duration(rnd.Intn(14*24*60)) * time.Minute)
+		arrival := departure.Add(time.Duration(60+rnd.Intn(8*60)) * time.Minute)
+		g.AddEdge(from, to, &flight{id: id, departure: departure, arrival: arrival})
+	}
+	return g
+}
+
+func BenchmarkGetPaths(b *testing.B) {
+	g := fixture(2, 10, 1000, 14)
+	b.Run("bfs", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			plainPaths(g, node(0), node(9), 4, 0)
+		}
+	})
+	b.Run("bounds", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			g.GetPaths(node(0), node(9), 4)
+		}
+	})
+}
+
+func BenchmarkFastestPaths(b *testing.B) {
+	g := fixture(2, 10, 1000, 14)
+	b.Run("criterion", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			g.SearchOptimalPaths(node(0), node(9), 4, &fastest{})
+		}
+	})
+	b.Run("astar", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			g.SearchFastestPaths(context.Background(), node(0), node(9), 4, nil)
+		}
+	})
+}
+
+func BenchmarkHubConnections(b *testing.B) {
+	scan, indexed := hubFixture(3, 50, 10000), hubFixture(3, 50, 10000)
+	indexed.Index()
+	layover := &Budget{MaxLayover: 24 * time.Hour}
+
+	for _, bench := range []struct {
+		name   string
+		graph  *Graph
+		budget *Budget
+	}{
+		{"scan", scan, nil},
+		{"index", indexed, nil},
+		{"scan-24h", scan, layover},
+		{"index-24h", indexed, layover},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bench.graph.GetPathsContext(context.Background(), "S00", "S01", 3, bench.budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package graph
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+//TimedEdge is an Edge with schedule. Searches over graph of timed edges prune paths which can't reach the
+//destination in time and may be guided by travel time. ConnectsTo of the previous edge must agree with
+//IsAccessibleFrom of the next one, arrival of an edge must be after its departure
+type TimedEdge interface {
+	Edge
+	DepartureTime() time.Time
+	ArrivalTime() time.Time
+	//ConnectsTo detects if edge arrives in time to transfer to an edge departing at departure
+	ConnectsTo(departure time.Time) bool
+}
+
+//ErrUntimedGraph is returned by searches which require every edge to be TimedEdge
+var ErrUntimedGraph = errors.New("graph: edges have no schedule")
+
+//bounds are per node bounds of paths to a destination node
+type bounds struct {
+	to     int
+	hops   []int       //minimum number of edges to the destination, -1 if it is unreachable
+	latest []time.Time //latest departure which reaches the destination in time, zero if there is none
+	timed  bool
+}
+
+//timed detects if every edge of graph is TimedEdge
+func (g *Graph) timed() bool {
+	if g.departures != nil {
+		return true
+	}
+	for u := range g.edges {
+		for _, e := range g.edges[u] {
+			if _, ok := e.value.(TimedEdge); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//bounds computes reverse reachability of destination node. Hops are counted by reverse BFS ignoring schedule,
+//latest departures are found by a sweep over timed edges in descending departure order: an edge reaches the
+//destination if it arrives to it or connects to a later departure which reaches it. Indexed graph keeps the order
+func (g *Graph) bounds(to int) *bounds {
+	b := &bounds{to: to, hops: make([]int, g.numNodes), timed: g.timed()}
+
+	reverse := make([][]int, g.numNodes)
+	var timed []*edge
+	for u := range g.edges {
+		for idx := range g.edges[u] {
+			e := &g.edges[u][idx]
+			reverse[e.to] = append(reverse[e.to], e.from)
+			if b.timed && g.latest == nil {
+				timed = append(timed, e)
+			}
+		}
+	}
+
+	for idx := range b.hops {
+		b.hops[idx] = -1
+	}
+	b.hops[to] = 0
+	queue := []int{to}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, u := range reverse[v] {
+			if b.hops[u] < 0 {
+				b.hops[u] = b.hops[v] + 1
+				queue = append(queue, u)
+			}
+		}
+	}
+
+	if !b.timed {
+		return b
+	}
+	if g.latest != nil {
+		timed = g.latest
+	} else {
+		sort.Slice(timed, func(i, j int) bool {
+			return timed[i].value.(TimedEdge).DepartureTime().After(timed[j].value.(TimedEdge).DepartureTime())
+		})
+	}
+	b.latest = make([]time.Time, g.numNodes)
+	for _, e := range timed {
+		value := e.value.(TimedEdge)
+		if e.from == to {
+			continue
+		}
+		if e.to == to || (!b.latest[e.to].IsZero() && value.ConnectsTo(b.latest[e.to])) {
+			if departure := value.DepartureTime(); departure.After(b.latest[e.from]) {
+				b.latest[e.from] = departure
+			}
+		}
+	}
+	return b
+}
+
+//viable detects if path of length edges ending with e may be extended to the destination within maxLength edges
+func (b *bounds) viable(e *edge, length int, maxLength int) bool {
+	hops := b.hops[e.to]
+	if hops < 0 || (maxLength > 0 && length+hops > maxLength) {
+		return false
+	}
+	if e.to == b.to || !b.timed {
+		return true
+	}
+	return !b.latest[e.to].IsZero() && e.value.(TimedEdge).ConnectsTo(b.latest[e.to])
+}
+
+//reachableAfter detects if node v may be reached by a continuation of path ending with e having at most hops
+//more edges, the destination excluded
+func (b *bounds) reachableAfter(v int, e *edge, hops int) bool {
+	if b.hops[v] < 0 || b.hops[v] > hops {
+		return false
+	}
+	return !b.timed || b.latest[v].After(e.value.(TimedEdge).ArrivalTime())
+}
+
+//duration returns time from the first departure to the last arrival of path of timed edges
+func duration(path []edge) time.Duration {
+	return path[len(path)-1].value.(TimedEdge).ArrivalTime().Sub(path[0].value.(TimedEdge).DepartureTime())
+}
+
+//remaining computes lower bounds of travel time from nodes to the destination: reverse Dijkstra over the
+//shortest edge durations, layovers are ignored. Bounds are consistent, so A* pops paths in order of duration
+func (g *Graph) remaining(to int) []time.Duration {
+	const unreachable = time.Duration(1<<63 - 1)
+
+	shortest := make(map[[2]int]time.Duration)
+	reverse := make([][]int, g.numNodes)
+	for u := range g.edges {
+		for _, e := range g.edges[u] {
+			value := e.value.(TimedEdge)
+			d := value.ArrivalTime().Sub(value.DepartureTime())
+			key := [2]int{e.from, e.to}
+			if current, ok := shortest[key]; !ok {
+				reverse[e.to] = append(reverse[e.to], e.from)
+				shortest[key] = d
+			} else if d < current {
+				shortest[key] = d
+			}
+		}
+	}
+
+	result := make([]time.Duration, g.numNodes)
+	for idx := range result {
+		result[idx] = unreachable
+	}
+	result[to] = 0
+	done := make([]bool, g.numNodes)
+	for {
+		v := -1
+		for idx := range result {
+			if !done[idx] && result[idx] != unreachable && (v < 0 || result[idx] < result[v]) {
+				v = idx
+			}
+		}
+		if v < 0 {
+			return result
+		}
+		done[v] = true
+		for _, u := range reverse[v] {
+			if d := result[v] + shortest[[2]int{u, v}]; d < result[u] {
+				result[u] = d
+			}
+		}
+	}
+}
+
+//guided is a path queued by A* search. Order is a sequence of edge positions in node edges, it sorts paths
+//of the same length in the order BFS would find them
+type guided struct {
+	edges    []edge
+	order    []int
+	estimate time.Duration
+}
+
+type guidedQueue []*guided
+
+func (q guidedQueue) Len() int            { return len(q) }
+func (q guidedQueue) Less(i, j int) bool  { return q[i].estimate < q[j].estimate }
+func (q guidedQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *guidedQueue) Push(x interface{}) { *q = append(*q, x.(*guided)) }
+func (q *guidedQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+//SearchFastestPaths finds paths between two nodes with the shortest time from the first departure to the last
+//arrival, the same paths and in the same order as a minimum time criterion of SearchOptimalPathsContext would
+//get. Search is A* guided by lower bounds of remaining travel time, so slower paths aren't expanded. Every edge
+//must be TimedEdge. Budget MaxPaths limits number of returned paths
+func (g *Graph) SearchFastestPaths(ctx context.Context, from string, to string, limit int, budget *Budget) ([]Path, SearchStats, error) {
+	fromIdx, fromExists := g.nodeLabels[from]
+	toIdx, toExists := g.nodeLabels[to]
+
+	if from == to || !fromExists || !toExists {
+		return nil, SearchStats{}, nil
+	}
+	if !g.timed() {
+		return nil, SearchStats{}, ErrUntimedGraph
+	}
+	if budget == nil {
+		budget = &Budget{}
+	}
+
+	b := g.bounds(toIdx)
+	remaining := g.remaining(toIdx)
+	maxLength := 0
+	if limit > 0 {
+		maxLength = limit - 1
+	}
+
+	var stats SearchStats
+	var queue guidedQueue
+	var buf []int //connections of the expanded edge, see connections
+	push := func(item *guided) bool {
+		if budget.MaxStates > 0 && stats.States >= budget.MaxStates {
+			return false
+		}
+		stats.States++
+		item.estimate = duration(item.edges) + remaining[item.edges[len(item.edges)-1].to]
+		heap.Push(&queue, item)
+		return true
+	}
+
+	var found []*guided
+	var best time.Duration
+	for idx := range g.edges[fromIdx] {
+		e := &g.edges[fromIdx][idx]
+		if b.viable(e, 1, maxLength) && !push(&guided{edges: []edge{*e}, order: []int{idx}}) {
+			stats.Truncated, stats.Reason = true, ReasonMaxStates
+			break
+		}
+	}
+
+	for expanded := 1; queue.Len() > 0 && !stats.Truncated; expanded++ {
+		if expanded%deadlineCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, stats, err
+			}
+			if !budget.Deadline.IsZero() && time.Now().After(budget.Deadline) {
+				stats.Truncated, stats.Reason = true, ReasonDeadline
+				break
+			}
+		}
+
+		item := heap.Pop(&queue).(*guided)
+		if len(found) > 0 && item.estimate > best {
+			break
+		}
+		if limit > 0 && len(item.edges) == limit {
+			continue
+		}
+
+		current := &item.edges[len(item.edges)-1]
+		if current.to == toIdx {
+			if budget.MaxPaths > 0 && len(found) >= budget.MaxPaths {
+				stats.Truncated, stats.Reason = true, ReasonMaxPaths
+				break
+			}
+			best = item.estimate
+			found = append(found, item)
+			continue
+		}
+
+		for _, idx := range g.connections(current, budget.MaxLayover, &buf) {
+			e := &g.edges[current.to][idx]
+			if onPath(item.edges, fromIdx, e.to) || !e.value.IsAccessibleFrom(current.value) || !b.viable(e, len(item.edges)+1, maxLength) {
+				continue
+			}
+			extended := &guided{edges: make([]edge, len(item.edges)+1), order: make([]int, len(item.order)+1)}
+			copy(extended.edges, item.edges)
+			copy(extended.order, item.order)
+			extended.edges[len(item.edges)] = *e
+			extended.order[len(item.order)] = idx
+			if !push(extended) {
+				stats.Truncated, stats.Reason = true, ReasonMaxStates
+				break
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i].order, found[j].order
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		for idx := range a {
+			if a[idx] != b[idx] {
+				return a[idx] < b[idx]
+			}
+		}
+		return false
+	})
+	paths := make([]Path, len(found))
+	for idx, item := range found {
+		paths[idx] = Path{edges: item.edges}
+	}
+	stats.Paths = len(paths)
+	return paths, stats, nil
+}
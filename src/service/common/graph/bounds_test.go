@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+//plainPaths finds paths by BFS over node edges in insertion order without bounds and index, as route search
+//did before them. Positive maxLayover limits time between arrival and the next departure of timed edges
+func plainPaths(g *Graph, from string, to string, limit int, maxLayover time.Duration) [][]edge {
+	fromIdx, toIdx := g.nodeLabels[from], g.nodeLabels[to]
+
+	var result [][]edge
+	var queue [][]edge
+	for _, e := range g.edges[fromIdx] {
+		queue = append(queue, []edge{e})
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if limit > 0 && len(path) == limit {
+			continue
+		}
+		last := path[len(path)-1]
+		if last.to == toIdx {
+			result = append(result, path)
+			continue
+		}
+	next:
+		for _, e := range g.edges[last.to] {
+			if e.to == fromIdx || !e.value.IsAccessibleFrom(last.value) {
+				continue
+			}
+			if maxLayover > 0 && e.value.(TimedEdge).DepartureTime().After(last.value.(TimedEdge).ArrivalTime().Add(maxLayover)) {
+				continue
+			}
+			for _, visited := range path {
+				if visited.to == e.to {
+					continue next
+				}
+			}
+			extended := make([]edge, len(path)+1)
+			copy(extended, path)
+			extended[len(path)] = e
+			queue = append(queue, extended)
+		}
+	}
+	return result
+}
+
+func signatures(paths []Path) []string {
+	var result []string
+	for idx := range paths {
+		result = append(result, signature(&paths[idx]))
+	}
+	return result
+}
+
+func edgeSignatures(paths [][]edge) []string {
+	var result []string
+	for _, edges := range paths {
+		result = append(result, signature(&Path{edges: edges}))
+	}
+	return result
+}
+
+func travelTime(edges []edge) time.Duration {
+	return edges[len(edges)-1].value.(TimedEdge).ArrivalTime().Sub(edges[0].value.(TimedEdge).DepartureTime())
+}
+
+func TestGetPathsMatchesPlainSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		limit int
+	}{
+		{"timed", fixture(1, 6, 60, 3), 0},
+		{"timed dense", fixture(2, 5, 120, 2), 0},
+		{"timed limited", fixture(3, 8, 150, 4), 3},
+		{"untimed", untimedFixture(4, 6, 20), 0},
+		{"untimed limited", untimedFixture(5, 7, 30), 4},
+	}
+	for _, test := range tests {
+		for from := 0; from < 5; from++ {
+			for to := 0; to < 5; to++ {
+				if from == to {
+					continue
+				}
+				want := edgeSignatures(plainPaths(test.graph, node(from), node(to), test.limit, 0))
+				got := signatures(test.graph.GetPaths(node(from), node(to), test.limit))
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("%s %s-%s: paths %v, want %v", test.name, node(from), node(to), got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchFastestPathsMatchesPlainSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		limit int
+	}{
+		{"sparse", fixture(1, 6, 60, 3), 0},
+		{"dense", fixture(2, 5, 120, 2), 0},
+		{"limited", fixture(3, 8, 150, 4), 3},
+		{"long", fixture(6, 10, 200, 7), 5},
+	}
+	for _, test := range tests {
+		for from := 0; from < 5; from++ {
+			for to := 0; to < 5; to++ {
+				if from == to {
+					continue
+				}
+				var want []string
+				var best time.Duration
+				for _, edges := range plainPaths(test.graph, node(from), node(to), test.limit, 0) {
+					switch d := travelTime(edges); {
+					case want == nil || d < best:
+						want, best = []string{signature(&Path{edges: edges})}, d
+					case d == best:
+						want = append(want, signature(&Path{edges: edges}))
+					}
+				}
+
+				paths, stats, err := test.graph.SearchFastestPaths(context.Background(), node(from), node(to), test.limit, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := signatures(paths); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("%s %s-%s: fastest paths %v, want %v", test.name, node(from), node(to), got, want)
+				}
+				if stats.Truncated {
+					t.Errorf("%s %s-%s: stats %+v, want complete search", test.name, node(from), node(to), stats)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchFastestPathsRequiresSchedule(t *testing.T) {
+	if _, _, err := untimedFixture(4, 6, 20).SearchFastestPaths(context.Background(), "A", "B", 0, nil); err != ErrUntimedGraph {
+		t.Fatalf("error %v, want %v", err, ErrUntimedGraph)
+	}
+}
@@ -0,0 +1,247 @@
+package graph
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+//maxPathLength returns the longest path search may find. Paths of limit edges are skipped by search and
+//paths without node revisits are shorter than number of nodes
+func (g *Graph) maxPathLength(limit int) int {
+	length := g.numNodes - 1
+	if limit > 0 && limit-1 < length {
+		length = limit - 1
+	}
+	return length
+}
+
+//addCount adds path counts saturating at math.MaxUint64
+func addCount(a, b uint64) uint64 {
+	if sum := a + b; sum >= a {
+		return sum
+	}
+	return math.MaxUint64
+}
+
+//CountPaths counts paths GetPathsContext would find between two nodes without building them. Index i of
+//result is a number of paths of i+1 edges. Counts are dynamic programming over states of the last edge, its
+//depth and the visited nodes a continuation may still reach. Nodes too far from the destination or without
+//departures reaching it after the edge arrival can't be revisited, so on time ordered data a state is mostly
+//the edge and its depth. States are evaluated states, memoised counts are kept within MaxStates words. Counts
+//saturate at math.MaxUint64, counts of truncated search are lower bounds
+func (g *Graph) CountPaths(ctx context.Context, from string, to string, limit int, budget *Budget) ([]uint64, SearchStats, error) {
+	fromIdx, fromExists := g.nodeLabels[from]
+	toIdx, toExists := g.nodeLabels[to]
+
+	maxLength := g.maxPathLength(limit)
+	counts := make([]uint64, maxLength)
+	if from == to || !fromExists || !toExists || maxLength <= 0 {
+		return counts, SearchStats{}, nil
+	}
+	if budget == nil {
+		budget = &Budget{}
+	}
+
+	type key struct {
+		edge    *edge
+		depth   int
+		visited string
+	}
+
+	var stats SearchStats
+	var err error
+	b := g.bounds(toIdx)
+	memo := make(map[key][]uint64)
+	memoSize := 0
+	path := []int{fromIdx}
+	visited := make([]bool, g.numNodes)
+	visited[fromIdx] = true
+
+	//state returns key of path of depth edges ending with e by visited nodes a continuation may reach
+	state := func(e *edge, depth int) key {
+		var reachable []int
+		for _, v := range path {
+			if b.reachableAfter(v, e, maxLength-depth-1) {
+				reachable = append(reachable, v)
+			}
+		}
+		sort.Ints(reachable)
+		buf := make([]byte, 0, 4*len(reachable))
+		for _, v := range reachable {
+			buf = append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+		}
+		return key{e, depth, string(buf)}
+	}
+
+	//count returns numbers of paths by number of edges added to path of depth edges ending with e
+	var count func(e *edge, depth int) []uint64
+	count = func(e *edge, depth int) []uint64 {
+		k := state(e, depth)
+		if result, ok := memo[k]; ok {
+			return result
+		}
+		if stats.Truncated || err != nil {
+			return nil
+		}
+		if budget.MaxStates > 0 && stats.States >= budget.MaxStates {
+			stats.Truncated, stats.Reason = true, ReasonMaxStates
+			return nil
+		}
+		stats.States++
+		if stats.States%deadlineCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return nil
+			}
+			if !budget.Deadline.IsZero() && time.Now().After(budget.Deadline) {
+				stats.Truncated, stats.Reason = true, ReasonDeadline
+				return nil
+			}
+		}
+
+		var result []uint64
+		if e.to == toIdx {
+			result = []uint64{1}
+		} else if depth < maxLength {
+			result = make([]uint64, maxLength-depth+1)
+			for _, idx := range g.following(e, budget.MaxLayover) {
+				next := &g.edges[e.to][idx]
+				if visited[next.to] || !next.value.IsAccessibleFrom(e.value) || !b.viable(next, depth+1, maxLength) {
+					continue
+				}
+				visited[next.to] = true
+				path = append(path, next.to)
+				for added, n := range count(next, depth+1) {
+					result[added+1] = addCount(result[added+1], n)
+				}
+				path = path[:len(path)-1]
+				visited[next.to] = false
+			}
+			for len(result) > 0 && result[len(result)-1] == 0 {
+				result = result[:len(result)-1]
+			}
+		}
+		if size := len(result) + len(k.visited)/8 + 4; !stats.Truncated && err == nil && (budget.MaxStates <= 0 || memoSize+size <= budget.MaxStates) {
+			memo[k] = result
+			memoSize += size
+		}
+		return result
+	}
+
+	for idx := range g.edges[fromIdx] {
+		e := &g.edges[fromIdx][idx]
+		if visited[e.to] || !b.viable(e, 1, maxLength) {
+			continue
+		}
+		visited[e.to] = true
+		path = append(path, e.to)
+		for added, n := range count(e, 1) {
+			counts[added] = addCount(counts[added], n)
+		}
+		path = path[:len(path)-1]
+		visited[e.to] = false
+		if err != nil {
+			return nil, stats, err
+		}
+	}
+
+	for _, n := range counts {
+		if n > uint64(math.MaxInt-stats.Paths) {
+			stats.Paths = math.MaxInt
+			break
+		}
+		stats.Paths += int(n)
+	}
+	return counts, stats, nil
+}
+
+//addEstimate adds path estimates saturating at math.MaxFloat64
+func addEstimate(a, b float64) float64 {
+	return math.Min(a+b, math.MaxFloat64)
+}
+
+//EstimatePaths estimates numbers of paths between two nodes by number of edges in time proportional to
+//maximum path length and number of connected edge pairs. Node revisits other than returns to the origin aren't
+//tracked, so estimate is an upper bound of CountPaths. States are connected edge pairs evaluated, truncated
+//search returns estimates of lengths finished so far. Estimates saturate at math.MaxFloat64
+func (g *Graph) EstimatePaths(ctx context.Context, from string, to string, limit int, budget *Budget) ([]float64, SearchStats, error) {
+	fromIdx, fromExists := g.nodeLabels[from]
+	toIdx, toExists := g.nodeLabels[to]
+
+	maxLength := g.maxPathLength(limit)
+	estimates := make([]float64, maxLength)
+	if from == to || !fromExists || !toExists || maxLength <= 0 {
+		return estimates, SearchStats{}, nil
+	}
+	if budget == nil {
+		budget = &Budget{}
+	}
+
+	//edges are numbered by node and position in node edges
+	offsets := make([]int, g.numNodes+1)
+	for u := range g.edges {
+		offsets[u+1] = offsets[u] + len(g.edges[u])
+	}
+
+	//ways[id] is a number of walks to the destination starting with edge id and having length more edges
+	ways := make([]float64, offsets[g.numNodes])
+	next := make([]float64, len(ways))
+	for u := range g.edges {
+		for idx, e := range g.edges[u] {
+			if e.to == toIdx {
+				ways[offsets[u]+idx] = 1
+			}
+		}
+	}
+
+	var stats SearchStats
+	checked := 0
+	for length := 0; ; length++ {
+		for idx := range g.edges[fromIdx] {
+			estimates[length] = addEstimate(estimates[length], ways[offsets[fromIdx]+idx])
+		}
+		if length+1 == maxLength {
+			break
+		}
+
+		reachable := false
+		for u := range g.edges {
+			for idx := range g.edges[u] {
+				if checked++; checked%deadlineCheckInterval == 0 {
+					if err := ctx.Err(); err != nil {
+						return nil, stats, err
+					}
+					if !budget.Deadline.IsZero() && time.Now().After(budget.Deadline) {
+						stats.Truncated, stats.Reason = true, ReasonDeadline
+						return estimates[:length+1], stats, nil
+					}
+				}
+
+				e := &g.edges[u][idx]
+				var sum float64
+				if e.to != toIdx {
+					candidates := g.following(e, budget.MaxLayover)
+					if budget.MaxStates > 0 && stats.States+len(candidates) > budget.MaxStates {
+						stats.Truncated, stats.Reason = true, ReasonMaxStates
+						return estimates[:length+1], stats, nil
+					}
+					stats.States += len(candidates)
+					for _, n := range candidates {
+						following := &g.edges[e.to][n]
+						if following.to != fromIdx && following.value.IsAccessibleFrom(e.value) {
+							sum = addEstimate(sum, ways[offsets[e.to]+n])
+						}
+					}
+				}
+				next[offsets[u]+idx] = sum
+				reachable = reachable || sum > 0
+			}
+		}
+		ways, next = next, ways
+		if !reachable {
+			break
+		}
+	}
+	return estimates, stats, nil
+}
@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+//lengths counts paths by number of edges
+func lengths(paths []Path, maxLength int) []uint64 {
+	counts := make([]uint64, maxLength)
+	for _, p := range paths {
+		counts[len(p.edges)-1]++
+	}
+	return counts
+}
+
+func TestCountPathsMatchesSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		limit int
+	}{
+		{"timed", fixture(1, 6, 60, 3), 0},
+		{"timed dense", fixture(2, 5, 120, 2), 0},
+		{"timed limited", fixture(3, 8, 150, 4), 3},
+		{"untimed", untimedFixture(4, 6, 20), 0},
+		{"untimed limited", untimedFixture(5, 7, 30), 4},
+	}
+	for _, test := range tests {
+		test.graph.Index()
+		for _, layover := range []time.Duration{0, 12 * time.Hour} {
+			budget := &Budget{MaxLayover: layover}
+			for from := 0; from < 4; from++ {
+				for to := 0; to < 4; to++ {
+					paths, _, err := test.graph.GetPathsContext(context.Background(), node(from), node(to), test.limit, budget)
+					if err != nil {
+						t.Fatal(err)
+					}
+					counts, stats, err := test.graph.CountPaths(context.Background(), node(from), node(to), test.limit, budget)
+					if err != nil {
+						t.Fatal(err)
+					}
+					want := lengths(paths, test.graph.maxPathLength(test.limit))
+					if len(counts) != len(want) {
+						t.Fatalf("%s %s-%s: %d counts, want %d", test.name, node(from), node(to), len(counts), len(want))
+					}
+					for idx := range want {
+						if counts[idx] != want[idx] {
+							t.Errorf("%s layover %v %s-%s: counts %v, want %v", test.name, layover, node(from), node(to), counts, want)
+							break
+						}
+					}
+					if stats.Paths != len(paths) || stats.Truncated {
+						t.Errorf("%s %s-%s: stats %+v, want %d paths", test.name, node(from), node(to), stats, len(paths))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCountPathsBudget(t *testing.T) {
+	g := fixture(2, 5, 120, 2)
+	g.Index()
+
+	full, _, err := g.CountPaths(context.Background(), "A", "B", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, stats, err := g.CountPaths(context.Background(), "A", "B", 0, &Budget{MaxStates: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Truncated || stats.Reason != ReasonMaxStates || stats.States > 10 {
+		t.Fatalf("stats %+v, want truncation by max states", stats)
+	}
+	for idx := range counts {
+		if counts[idx] > full[idx] {
+			t.Fatalf("truncated counts %v exceed %v", counts, full)
+		}
+	}
+}
+
+func TestEstimatePathsIsUpperBound(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		g := fixture(seed, 6, 80, 3)
+		g.Index()
+
+		counts, _, err := g.CountPaths(context.Background(), "A", "B", 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		estimates, stats, err := g.EstimatePaths(context.Background(), "A", "B", 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Truncated {
+			t.Fatalf("seed %d: unlimited estimate truncated: %+v", seed, stats)
+		}
+		for idx := range counts {
+			if estimates[idx] < float64(counts[idx]) {
+				t.Errorf("seed %d: estimates %v below counts %v", seed, estimates, counts)
+				break
+			}
+		}
+	}
+}
+
+func TestEstimatePathsBudget(t *testing.T) {
+	g := fixture(2, 5, 120, 2)
+	g.Index()
+
+	estimates, stats, err := g.EstimatePaths(context.Background(), "A", "B", 0, &Budget{MaxStates: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Truncated || stats.Reason != ReasonMaxStates || stats.States > 100 {
+		t.Fatalf("stats %+v, want truncation by max states", stats)
+	}
+	if len(estimates) == 0 || len(estimates) >= g.maxPathLength(0) {
+		t.Fatalf("truncated estimate of %d lengths, want finished lengths only", len(estimates))
+	}
+}
@@ -2,7 +2,9 @@ package graph
 
 import (
 	"container/list"
+	"context"
 	"fmt"
+	"time"
 )
 
 //Graph is a data struct to store ordered graph with arbitrary node labels
@@ -10,6 +12,11 @@ type Graph struct {
 	numNodes   int
 	edges      [][]edge
 	nodeLabels map[string]int
+	positions  []int         //0, 1, 2... up to the largest number of node edges
+	order      [][]int       //indexes of node edges in departure order, nil if graph isn't indexed
+	departures [][]time.Time //departures of node edges in departure order
+	starts     [][]int64     //departures of node edges in insertion order, unix nanoseconds
+	latest     []*edge       //edges of indexed graph in descending departure order
 }
 
 //Edge interface for edge value
@@ -43,6 +50,24 @@ func (p *Path) Edges() []Edge {
 	return edges
 }
 
+//NewPath creates detached path by edge values, e.g. to evaluate a known route with criteria
+func NewPath(values ...Edge) *Path {
+	edges := make([]edge, len(values))
+	for idx, value := range values {
+		edges[idx] = edge{value: value}
+	}
+	return &Path{edges: edges}
+}
+
+//JoinPaths concatenates paths into a single path
+func JoinPaths(paths ...*Path) *Path {
+	var edges []edge
+	for _, p := range paths {
+		edges = append(edges, p.edges...)
+	}
+	return &Path{edges: edges}
+}
+
 //NewGraph creates graph with number of nodes required
 func NewGraph(n int) *Graph {
 	return &Graph{
@@ -71,84 +96,192 @@ func (g *Graph) AddEdge(from string, to string, value Edge) {
 	}
 
 	g.edges[u] = append(g.edges[u], edge{from: u, to: v, value: value})
+	if n := len(g.edges[u]); n > len(g.positions) {
+		g.positions = append(g.positions, n-1)
+	}
+	g.order, g.departures, g.starts, g.latest = nil, nil, nil, nil
+}
+
+//Budget limits graph search. Zero fields are unlimited
+type Budget struct {
+	MaxStates  int           //paths queued for expansion
+	MaxPaths   int           //paths found
+	Deadline   time.Time     //wall-clock limit
+	MaxLayover time.Duration //time between arrival and the next departure of timed edges
+}
+
+//Search truncation reasons
+const (
+	ReasonMaxStates = "max_states"
+	ReasonMaxPaths  = "max_routes"
+	ReasonDeadline  = "deadline"
+)
+
+//deadlineCheckInterval is a number of expanded paths between deadline and context checks
+const deadlineCheckInterval = 256
+
+//SearchStats describes finished search. Truncated search was stopped due Budget with unexplored paths left
+type SearchStats struct {
+	States    int    `json:"states"`
+	Paths     int    `json:"paths"`
+	Truncated bool   `json:"truncated"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+//Add sums stats of several searches, the first truncation reason is kept
+func (s *SearchStats) Add(other SearchStats) {
+	s.States += other.States
+	s.Paths += other.Paths
+	if other.Truncated && !s.Truncated {
+		s.Truncated, s.Reason = true, other.Reason
+	}
 }
 
 //GetPaths search paths between two nodes. Pass limit greater than zero to set maximim path length
 func (g *Graph) GetPaths(from string, to string, limit int) []Path {
+	paths, _, _ := g.GetPathsContext(context.Background(), from, to, limit, nil)
+	return paths
+}
+
+//GetPathsContext search paths between two nodes until budget is exhausted. Nil budget is unlimited. Search
+//is abandoned with ctx error, and no paths, when ctx is done
+func (g *Graph) GetPathsContext(ctx context.Context, from string, to string, limit int, budget *Budget) ([]Path, SearchStats, error) {
 	var result []Path
 
 	fromIdx, fromExists := g.nodeLabels[from]
 	toIdx, toExists := g.nodeLabels[to]
 
 	if from == to || !fromExists || !toExists {
-		return result
+		return result, SearchStats{}, nil
 	}
 
-	paths := g.getPaths(fromIdx, toIdx, limit)
-
-	for _, edges := range paths {
+	stats, err := g.search(ctx, fromIdx, toIdx, limit, budget, func(edges []edge) error {
 		result = append(result, Path{
 			edges: edges,
 		})
+		return nil
+	})
+	if err != nil {
+		return nil, stats, err
 	}
-	return result
+	return result, stats, nil
 }
 
-//based on BFS algorithm
-func (g *Graph) getPaths(from int, to int, limit int) [][]edge {
+//WalkPaths search paths between two nodes like GetPathsContext, but passes every path to fn as soon as it is
+//found instead of collecting them. Search stops with fn error
+func (g *Graph) WalkPaths(ctx context.Context, from string, to string, limit int, budget *Budget, fn func(path *Path) error) (SearchStats, error) {
+	fromIdx, fromExists := g.nodeLabels[from]
+	toIdx, toExists := g.nodeLabels[to]
 
-	type queueItem struct {
-		path    []edge
-		visited []bool
+	if from == to || !fromExists || !toExists {
+		return SearchStats{}, nil
 	}
 
-	var path []edge
-	visited := make([]bool, g.numNodes)
-	visited[from] = true
+	return g.search(ctx, fromIdx, toIdx, limit, budget, func(edges []edge) error {
+		return fn(&Path{edges: edges})
+	})
+}
+
+//onPath detects if node is the path origin or is reached by any path edge
+func onPath(path []edge, origin int, node int) bool {
+	if node == origin {
+		return true
+	}
+	for _, e := range path {
+		if e.to == node {
+			return true
+		}
+	}
+	return false
+}
 
+//search is based on BFS algorithm, found is called for every path to the destination node. Every queued path
+//has its own edges, so found paths may be kept. Paths don't visit a node twice. Paths which can't reach
+//the destination by bounds are not queued. Queued paths are released when search stops due budget, ctx or found error
+func (g *Graph) search(ctx context.Context, from int, to int, limit int, budget *Budget, found func(path []edge) error) (SearchStats, error) {
+	if budget == nil {
+		budget = &Budget{}
+	}
+	b := g.bounds(to)
+	maxLength := 0
+	if limit > 0 {
+		maxLength = limit - 1
+	}
+
+	var stats SearchStats
 	queue := list.New()
-	for _, edge := range g.edges[from] {
-		queue.PushBack(queueItem{
-			path:    append(path, edge),
-			visited: visited,
-		})
+	var buf []int //connections of the expanded edge, see connections
+	truncate := func(reason string) (SearchStats, error) {
+		stats.Truncated, stats.Reason = true, reason
+		queue.Init()
+		return stats, nil
+	}
+	push := func(path []edge) bool {
+		if budget.MaxStates > 0 && stats.States >= budget.MaxStates {
+			return false
+		}
+		stats.States++
+		queue.PushBack(path)
+		return true
 	}
 
-	var paths [][]edge
+	for idx := range g.edges[from] {
+		if e := &g.edges[from][idx]; b.viable(e, 1, maxLength) && !push([]edge{*e}) {
+			return truncate(ReasonMaxStates)
+		}
+	}
 
-	for {
+	for expanded := 1; ; expanded++ {
 		next := queue.Front()
 		if next == nil {
 			break
 		}
 		queue.Remove(next)
 
-		item := next.Value.(queueItem)
+		if expanded%deadlineCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				queue.Init()
+				return stats, err
+			}
+			if !budget.Deadline.IsZero() && time.Now().After(budget.Deadline) {
+				return truncate(ReasonDeadline)
+			}
+		}
+
+		path := next.Value.([]edge)
 
-		pathLength := len(item.path)
+		pathLength := len(path)
 		if pathLength == 0 || (limit > 0 && pathLength == limit) {
 			continue
 		}
 
-		currentEdge := &item.path[len(item.path)-1]
+		currentEdge := &path[len(path)-1]
 
 		if currentEdge.to == to {
-			paths = append(paths, item.path)
+			stats.Paths++
+			if err := found(path); err != nil {
+				queue.Init()
+				return stats, err
+			}
+			if budget.MaxPaths > 0 && stats.Paths >= budget.MaxPaths && queue.Len() > 0 {
+				return truncate(ReasonMaxPaths)
+			}
 			continue
 		}
 
-		item.visited[currentEdge.to] = true
-
-		for _, edge := range g.edges[currentEdge.to] {
-			if !visited[edge.to] && edge.value.IsAccessibleFrom(currentEdge.value) {
-				queue.PushBack(queueItem{
-					path:    append(item.path, edge),
-					visited: item.visited,
-				})
+		for _, idx := range g.connections(currentEdge, budget.MaxLayover, &buf) {
+			e := &g.edges[currentEdge.to][idx]
+			if !onPath(path, from, e.to) && e.value.IsAccessibleFrom(currentEdge.value) && b.viable(e, len(path)+1, maxLength) {
+				extended := make([]edge, len(path)+1)
+				copy(extended, path)
+				extended[len(path)] = *e
+				if !push(extended) {
+					return truncate(ReasonMaxStates)
+				}
 			}
 		}
 	}
-	return paths
+	return stats, nil
 }
 
 //Print output simple debug info
@@ -160,66 +293,24 @@ func (g *Graph) Print() {
 
 //SearchOptimalPaths search optimal paths between two nodes by given criteria
 func (g *Graph) SearchOptimalPaths(from string, to string, limit int, criteria ...OptimalCriterion) {
+	g.SearchOptimalPathsContext(context.Background(), from, to, limit, nil, criteria...)
+}
+
+//SearchOptimalPathsContext search optimal paths between two nodes by given criteria until budget is exhausted.
+//Criteria of truncated search are applied to the paths found so far. Nil budget is unlimited. Search is
+//abandoned with ctx error when ctx is done, criteria results should be discarded then
+func (g *Graph) SearchOptimalPathsContext(ctx context.Context, from string, to string, limit int, budget *Budget, criteria ...OptimalCriterion) (SearchStats, error) {
 	fromIdx, fromExists := g.nodeLabels[from]
 	toIdx, toExists := g.nodeLabels[to]
 
 	if from == to || !fromExists || !toExists {
-		return
+		return SearchStats{}, nil
 	}
 
-	g.searchOptimalPaths(fromIdx, toIdx, limit, criteria...)
-}
-
-func (g *Graph) searchOptimalPaths(from int, to int, limit int, criteria ...OptimalCriterion) {
-	type queueItem struct {
-		path    []edge
-		visited []bool
-	}
-
-	var path []edge
-	visited := make([]bool, g.numNodes)
-	visited[from] = true
-
-	queue := list.New()
-	for _, edge := range g.edges[from] {
-		queue.PushBack(queueItem{
-			path:    append(path, edge),
-			visited: visited,
-		})
-	}
-
-	for {
-		next := queue.Front()
-		if next == nil {
-			break
-		}
-		queue.Remove(next)
-
-		item := next.Value.(queueItem)
-
-		pathLength := len(item.path)
-		if pathLength == 0 || (limit > 0 && pathLength == limit) {
-			continue
+	return g.search(ctx, fromIdx, toIdx, limit, budget, func(edges []edge) error {
+		for _, criterion := range criteria {
+			criterion.Apply(&Path{edges: edges})
 		}
-
-		currentEdge := &item.path[len(item.path)-1]
-
-		if currentEdge.to == to {
-			for _, criterion := range criteria {
-				criterion.Apply(&Path{edges: item.path})
-			}
-			continue
-		}
-
-		item.visited[currentEdge.to] = true
-
-		for _, edge := range g.edges[currentEdge.to] {
-			if !visited[edge.to] && edge.value.IsAccessibleFrom(currentEdge.value) {
-				queue.PushBack(queueItem{
-					path:    append(item.path, edge),
-					visited: item.visited,
-				})
-			}
-		}
-	}
+		return nil
+	})
 }
@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+//flight is a TimedEdge of fixture graphs, connections need an hour for transfer
+type flight struct {
+	id        int
+	departure time.Time
+	arrival   time.Time
+}
+
+func (f *flight) IsAccessibleFrom(from interface{}) bool {
+	return from.(*flight).ConnectsTo(f.departure)
+}
+
+func (f *flight) DepartureTime() time.Time {
+	return f.departure
+}
+
+func (f *flight) ArrivalTime() time.Time {
+	return f.arrival
+}
+
+func (f *flight) ConnectsTo(departure time.Time) bool {
+	return f.arrival.Before(departure.Add(-time.Hour))
+}
+
+func (f *flight) String() string {
+	return fmt.Sprint(f.id)
+}
+
+//untimed is an Edge without schedule, every edge is accessible
+type untimed struct {
+	id int
+}
+
+func (u *untimed) IsAccessibleFrom(from interface{}) bool {
+	return true
+}
+
+func (u *untimed) String() string {
+	return fmt.Sprint(u.id)
+}
+
+//fixture generates graph of n flights between nodes airports over days, flights are added in generation order
+func fixture(seed int64, nodes int, n int, days int) *Graph {
+	rnd := rand.New(rand.NewSource(seed))
+	start := time.Date(2018, 10, 22, 0, 0, 0, 0, time.UTC)
+
+	g := NewGraph(nodes)
+	for id := 0; id < n; id++ {
+		from, to := rnd.Intn(nodes), rnd.Intn(nodes)
+		departure := start.Add(time.Duration(rnd.Intn(days*24*60)) * time.Minute)
+		arrival := departure.Add(time.Duration(60+rnd.Intn(8*60)) * time.Minute)
+		g.AddEdge(node(from), node(to), &flight{id: id, departure: departure, arrival: arrival})
+	}
+	return g
+}
+
+//untimedFixture generates graph of n edges without schedule
+func untimedFixture(seed int64, nodes int, n int) *Graph {
+	rnd := rand.New(rand.NewSource(seed))
+
+	g := NewGraph(nodes)
+	for id := 0; id < n; id++ {
+		g.AddEdge(node(rnd.Intn(nodes)), node(rnd.Intn(nodes)), &untimed{id: id})
+	}
+	return g
+}
+
+func node(idx int) string {
+	return string(rune('A' + idx))
+}
+
+//signature returns edge ids of path, e.g. "3 17 4"
+func signature(p *Path) string {
+	return fmt.Sprint(p.Edges())
+}
+
+//edges creates graph of untimed edges given as "from to" pairs, edge ids are pair indexes
+func edges(pairs ...string) *Graph {
+	g := NewGraph(len(pairs) + 1)
+	for id, pair := range pairs {
+		g.AddEdge(pair[:1], pair[2:], &untimed{id: id})
+	}
+	return g
+}
+
+//TestGetPathsQueuedPathsAreIndependent covers the BFS of route search before budgets: queued paths shared
+//a single visited slice, so a node expanded by one path was skipped by others, and extensions of a path
+//appended to its edges, overwriting the last edge of sibling paths
+func TestGetPathsQueuedPathsAreIndependent(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		from  string
+		to    string
+		want  []string
+	}{
+		{"node expanded by other path", edges("A B", "A C", "C B", "B D"), "A", "D", []string{"[0 3]", "[1 2 3]"}},
+		{"sibling extensions", edges("A B", "B C", "C D", "D E", "D E"), "A", "E", []string{"[0 1 2 3]", "[0 1 2 4]"}},
+		{"no loops", edges("A B", "B A", "B C"), "A", "C", []string{"[0 2]"}},
+	}
+	for _, test := range tests {
+		var got []string
+		paths := test.graph.GetPaths(test.from, test.to, 0)
+		for idx := range paths {
+			got = append(got, signature(&paths[idx]))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: paths %v, want %v", test.name, got, test.want)
+		}
+	}
+}
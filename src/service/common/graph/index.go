@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"sort"
+	"time"
+)
+
+//Index builds a view of node edges sorted by departure, edges keep insertion order. Connections of an edge are
+//found by binary search of their departure window, searches return the same paths in the same
+//order. Graphs having edges other than TimedEdge aren't indexed, edges added after Index drop the index
+func (g *Graph) Index() {
+	if !g.timed() {
+		return
+	}
+
+	order := make([][]int, g.numNodes)
+	departures := make([][]time.Time, g.numNodes)
+	starts := make([][]int64, g.numNodes)
+	var latest []*edge
+	for u := range g.edges {
+		edges := g.edges[u]
+		order[u] = make([]int, len(edges))
+		starts[u] = make([]int64, len(edges))
+		for idx := range edges {
+			order[u][idx] = idx
+			starts[u][idx] = departure(&edges[idx]).UnixNano()
+			latest = append(latest, &edges[idx])
+		}
+		sort.SliceStable(order[u], func(i, j int) bool {
+			return departure(&edges[order[u][i]]).Before(departure(&edges[order[u][j]]))
+		})
+		departures[u] = make([]time.Time, len(edges))
+		for idx, position := range order[u] {
+			departures[u][idx] = departure(&edges[position])
+		}
+	}
+	sort.Slice(latest, func(i, j int) bool {
+		return departure(latest[i]).After(departure(latest[j]))
+	})
+	g.order, g.departures, g.starts, g.latest = order, departures, starts, latest
+}
+
+func departure(e *edge) time.Time {
+	return e.value.(TimedEdge).DepartureTime()
+}
+
+//connections returns indexes of e.to edges which may follow e in insertion order, see following. Indexed graph
+//finds the departure window by binary search and walks node edges once comparing their departures with it, so
+//the order is kept without sorting. Partial windows are collected into buf which is reused between calls
+func (g *Graph) connections(e *edge, maxLayover time.Duration, buf *[]int) []int {
+	if g.order == nil {
+		return g.following(e, maxLayover)
+	}
+	lo, hi := g.window(e, maxLayover)
+	switch {
+	case lo == hi:
+		return nil
+	case lo == 0 && hi == len(g.edges[e.to]):
+		return g.positions[:hi]
+	}
+
+	departures := g.departures[e.to]
+	first, last := departures[lo].UnixNano(), departures[hi-1].UnixNano()
+	result := (*buf)[:0]
+	for idx, departure := range g.starts[e.to] {
+		if departure >= first && departure <= last {
+			result = append(result, idx)
+		}
+	}
+	*buf = result
+	return result
+}
+
+//following returns indexes of e.to edges which may follow e in no particular order, for searches which don't
+//depend on it. Positive maxLayover limits time between e arrival and departure of timed edges, indexed graph finds
+//the departures by binary search. IsAccessibleFrom remains the final check
+func (g *Graph) following(e *edge, maxLayover time.Duration) []int {
+	edges := g.edges[e.to]
+	if g.order == nil {
+		value, timed := e.value.(TimedEdge)
+		if maxLayover <= 0 || !timed {
+			return g.positions[:len(edges)]
+		}
+		latest := value.ArrivalTime().Add(maxLayover)
+		var result []int
+		for idx := range edges {
+			if next, ok := edges[idx].value.(TimedEdge); !ok || !next.DepartureTime().After(latest) {
+				result = append(result, idx)
+			}
+		}
+		return result
+	}
+
+	lo, hi := g.window(e, maxLayover)
+	if lo == 0 && hi == len(edges) {
+		return g.positions[:len(edges)]
+	}
+	return g.order[e.to][lo:hi]
+}
+
+//window returns range of e.to departures of indexed graph which connect to e within maxLayover
+func (g *Graph) window(e *edge, maxLayover time.Duration) (int, int) {
+	departures := g.departures[e.to]
+	value := e.value.(TimedEdge)
+	lo := sort.Search(len(departures), func(i int) bool {
+		return value.ConnectsTo(departures[i])
+	})
+	hi := len(departures)
+	if maxLayover > 0 {
+		latest := value.ArrivalTime().Add(maxLayover)
+		hi = lo + sort.Search(hi-lo, func(i int) bool {
+			return departures[lo+i].After(latest)
+		})
+	}
+	return lo, hi
+}
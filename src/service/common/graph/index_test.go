@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIndexKeepsPathsAndOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		seed  int64
+		nodes int
+		n     int
+		days  int
+		limit int
+	}{
+		{"sparse", 1, 6, 60, 3, 0},
+		{"dense", 2, 5, 120, 2, 0},
+		{"limited", 3, 8, 150, 4, 3},
+		{"hub", 7, 4, 200, 1, 4},
+	}
+	for _, test := range tests {
+		indexed := fixture(test.seed, test.nodes, test.n, test.days)
+		indexed.Index()
+		if indexed.order == nil {
+			t.Fatalf("%s: timed graph isn't indexed", test.name)
+		}
+		plain := fixture(test.seed, test.nodes, test.n, test.days)
+
+		for _, layover := range []time.Duration{0, 6 * time.Hour, 24 * time.Hour} {
+			budget := &Budget{MaxLayover: layover}
+			for from := 0; from < test.nodes; from++ {
+				for to := 0; to < test.nodes; to++ {
+					if from == to {
+						continue
+					}
+					want := edgeSignatures(plainPaths(plain, node(from), node(to), test.limit, layover))
+					for _, g := range []*Graph{plain, indexed} {
+						paths, _, err := g.GetPathsContext(context.Background(), node(from), node(to), test.limit, budget)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if got := signatures(paths); fmt.Sprint(got) != fmt.Sprint(want) {
+							t.Errorf("%s layover %v %s-%s indexed %t: paths %v, want %v", test.name, layover, node(from), node(to), g.order != nil, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestIndexKeepsEdgeOrder(t *testing.T) {
+	g := fixture(2, 5, 120, 2)
+	before := fmt.Sprint(g.edges)
+	g.Index()
+	if after := fmt.Sprint(g.edges); after != before {
+		t.Fatal("Index reordered node edges")
+	}
+}
+
+func TestIndexSkipsUntimedGraph(t *testing.T) {
+	g := untimedFixture(4, 6, 20)
+	g.Index()
+	if g.order != nil {
+		t.Fatal("untimed graph is indexed")
+	}
+}
+
+func TestAddEdgeDropsIndex(t *testing.T) {
+	g := fixture(1, 6, 60, 3)
+	g.Index()
+	g.AddEdge("A", "B", &flight{id: 60, departure: time.Date(2018, 10, 22, 0, 0, 0, 0, time.UTC), arrival: time.Date(2018, 10, 22, 2, 0, 0, 0, time.UTC)})
+	if g.order != nil || g.departures != nil || g.latest != nil {
+		t.Fatal("index is kept after AddEdge")
+	}
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/r3labs/diff"
+)
+
+//keyedList is a list of items matched by keys, implemented by lists sharing diffKeyed
+type keyedList interface {
+	keys() []string
+	has(key string) bool
+	//compare compares item of the list to the item of other list with the same key
+	compare(key string, other keyedList) diff.Changelog
+}
+
+//keyedChange is a changelog of items with the same key
+type keyedChange struct {
+	key       string
+	changelog diff.Changelog
+}
+
+//diffKeyed compares listA to listB by workers. Returns sorted keys of added, removed and
+//changed items, changes ignored by options are dropped
+func diffKeyed(listA keyedList, listB keyedList, workers int, options *CompareOptions) (added []string, removed []string, changed []keyedChange) {
+	jobs := make(chan string)
+	results := make(chan keyedChange)
+
+	wgResults := sync.WaitGroup{}
+	wgResults.Add(1)
+	go func() {
+		defer wgResults.Done()
+		for result := range results {
+			changed = append(changed, result)
+		}
+	}()
+
+	wgWorkers := sync.WaitGroup{}
+	wgWorkers.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wgWorkers.Done()
+			for key := range jobs {
+				changelog := options.Filter(listA.compare(key, listB))
+				if len(changelog) > 0 {
+					results <- keyedChange{key, changelog}
+				}
+			}
+		}()
+	}
+
+	for _, key := range listA.keys() {
+		if listB.has(key) {
+			jobs <- key
+		} else {
+			removed = append(removed, key)
+		}
+	}
+	close(jobs)
+	wgWorkers.Wait()
+	close(results)
+	wgResults.Wait()
+
+	for _, key := range listB.keys() {
+		if !listA.has(key) {
+			added = append(added, key)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Slice(changed, func(i, j int) bool { return changed[i].key < changed[j].key })
+	return
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+//matchKeyFields flight attributes available for match keys
+var matchKeyFields = map[string]func(f *Flight) string{
+	"carrier":        func(f *Flight) string { return f.Carrier.Name },
+	"carrier_id":     func(f *Flight) string { return f.Carrier.ID },
+	"flight_number":  func(f *Flight) string { return f.FlightNumber },
+	"source":         func(f *Flight) string { return f.Source },
+	"destination":    func(f *Flight) string { return f.Destination },
+	"departure_date": func(f *Flight) string { return f.DepartureTimeStamp.Format("01-02-2006") },
+	"departure_time": func(f *Flight) string { return f.DepartureTimeStamp.Format("15:04") },
+	"arrival_date":   func(f *Flight) string { return f.ArrivalTimeStamp.Format("01-02-2006") },
+	"arrival_time":   func(f *Flight) string { return f.ArrivalTimeStamp.Format("15:04") },
+	"class":          func(f *Flight) string { return f.Class },
+	"fare_basis":     func(f *Flight) string { return f.FareBasis },
+	"ticket_type":    func(f *Flight) string { return f.TicketType },
+}
+
+//KeyProfile builds flight match keys from the list of fields
+type KeyProfile struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+//DefaultKeyProfile produces the same keys as Flight.Key
+var DefaultKeyProfile = KeyProfile{
+	Name:   "default",
+	Fields: []string{"carrier", "flight_number", "departure_date", "fare_basis"},
+}
+
+//KeyProfiles built-in match key profiles
+var KeyProfiles = map[string]KeyProfile{
+	"default": DefaultKeyProfile,
+	"carrier_id": {
+		Name:   "carrier_id",
+		Fields: []string{"carrier_id", "flight_number", "departure_date", "fare_basis"},
+	},
+	"no_fare_basis": {
+		Name:   "no_fare_basis",
+		Fields: []string{"carrier", "flight_number", "departure_date"},
+	},
+	"departure_time": {
+		Name:   "departure_time",
+		Fields: []string{"carrier", "flight_number", "departure_date", "departure_time", "fare_basis"},
+	},
+}
+
+//ParseKeyProfile returns built-in profile by name or custom profile by comma separated fields
+func ParseKeyProfile(name string, fields string) (*KeyProfile, error) {
+	if name != "" && fields != "" {
+		return nil, fmt.Errorf("match_key and match_fields are mutually exclusive")
+	}
+
+	if fields != "" {
+		profile := KeyProfile{Name: "custom"}
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if _, exist := matchKeyFields[field]; !exist {
+				return nil, fmt.Errorf("unknown match field %q", field)
+			}
+			profile.Fields = append(profile.Fields, field)
+		}
+		return &profile, nil
+	}
+
+	if name == "" {
+		name = DefaultKeyProfile.Name
+	}
+	profile, exist := KeyProfiles[name]
+	if !exist {
+		return nil, fmt.Errorf("unknown match key profile %q", name)
+	}
+	return &profile, nil
+}
+
+//Key returns flight match key
+func (p *KeyProfile) Key(f *Flight) string {
+	values := make([]string, len(p.Fields))
+	for idx, field := range p.Fields {
+		values[idx] = matchKeyFields[field](f)
+	}
+	return strings.Join(values, ":")
+}
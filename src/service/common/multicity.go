@@ -0,0 +1,221 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"service/common/graph"
+	"time"
+)
+
+//Leg is a single segment of multi-city itinerary
+type Leg struct {
+	Source        string `json:"source"`
+	Destination   string `json:"destination"`
+	DepartureFrom string `json:"departure_from"`
+	DepartureTo   string `json:"departure_to"`
+	MinStopover   int    `json:"min_stopover"` //minutes at leg destination before next leg, TransferTimeInMinutes by default
+	MaxStopover   int    `json:"max_stopover"` //minutes at leg destination before next leg, zero means unlimited
+
+	departureFrom time.Time
+	departureTo   time.Time
+}
+
+//ParseLegs decodes json array of legs and validates them
+func ParseLegs(raw string) ([]Leg, error) {
+	var legs []Leg
+	if err := json.Unmarshal([]byte(raw), &legs); err != nil {
+		return nil, err
+	}
+	if len(legs) == 0 {
+		return nil, errors.New("legs: at least one leg required")
+	}
+
+	for idx := range legs {
+		leg := &legs[idx]
+		if leg.Source == "" || leg.Destination == "" {
+			return nil, fmt.Errorf("legs[%d]: source and destination required", idx)
+		}
+		if leg.Source == leg.Destination {
+			return nil, fmt.Errorf("legs[%d]: source and destination must differ", idx)
+		}
+
+		var err error
+		if leg.departureFrom, err = parseWindowBound(leg.DepartureFrom, false); err != nil {
+			return nil, fmt.Errorf("legs[%d]: departure_from: %s", idx, err)
+		}
+		if leg.departureTo, err = parseWindowBound(leg.DepartureTo, true); err != nil {
+			return nil, fmt.Errorf("legs[%d]: departure_to: %s", idx, err)
+		}
+		if !leg.departureFrom.IsZero() && !leg.departureTo.IsZero() && leg.departureTo.Before(leg.departureFrom) {
+			return nil, fmt.Errorf("legs[%d]: departure_to is before departure_from", idx)
+		}
+
+		if leg.MinStopover < 0 || leg.MaxStopover < 0 {
+			return nil, fmt.Errorf("legs[%d]: stopover can't be negative", idx)
+		}
+		if leg.MinStopover == 0 {
+			leg.MinStopover = TransferTimeInMinutes
+		}
+		if leg.MaxStopover > 0 && leg.MaxStopover < leg.MinStopover {
+			return nil, fmt.Errorf("legs[%d]: max_stopover is less than min_stopover", idx)
+		}
+	}
+	return legs, nil
+}
+
+//parseWindowBound accepts "2006-01-02" or "2006-01-02T1504". Date only upper bound covers the whole day
+func parseWindowBound(value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02T1504", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, err
+	}
+	if upper {
+		t = t.Add(24*time.Hour - time.Minute)
+	}
+	return t, nil
+}
+
+//departsWithin detects if route departure is inside leg date window
+func (leg *Leg) departsWithin(route Route) bool {
+	departure := route.Flights[0].Flight.DepartureTimeStamp.Time
+	if !leg.departureFrom.IsZero() && departure.Before(leg.departureFrom) {
+		return false
+	}
+	if !leg.departureTo.IsZero() && departure.After(leg.departureTo) {
+		return false
+	}
+	return true
+}
+
+//allowsStopover detects if next route may follow previous one after this leg
+func (leg *Leg) allowsStopover(previous Route, next Route) bool {
+	arrival := previous.Flights[len(previous.Flights)-1].Flight.ArrivalTimeStamp.Time
+	departure := next.Flights[0].Flight.DepartureTimeStamp.Time
+
+	stopover := departure.Sub(arrival)
+	if stopover < time.Duration(leg.MinStopover)*time.Minute {
+		return false
+	}
+	return leg.MaxStopover == 0 || stopover <= time.Duration(leg.MaxStopover)*time.Minute
+}
+
+//Itinerary is a list of routes, one per Leg
+type Itinerary struct {
+	Legs []Route `json:"legs"`
+
+	paths []*graph.Path
+}
+
+//Path returns itinerary flights joined into a single path
+func (i *Itinerary) Path() *graph.Path {
+	return graph.JoinPaths(i.paths...)
+}
+
+//SearchItineraries combines routes of every leg into itineraries satisfying leg windows and stopovers
+func SearchItineraries(g *graph.Graph, legs []Leg, limit int) []*Itinerary {
+	itineraries, _, _ := SearchItinerariesContext(context.Background(), g, legs, limit, nil)
+	return itineraries
+}
+
+//combineCheckInterval is a number of combined itineraries between deadline and context checks
+const combineCheckInterval = 256
+
+//SearchItinerariesContext combines routes of every leg into itineraries until budget is exhausted. Legs share
+//the budget: states queued by a leg search are not available to the next legs. Budget MaxPaths limits routes
+//of every leg and number of itineraries. Nil budget is unlimited. Search is abandoned with ctx error when ctx
+//is done
+func SearchItinerariesContext(ctx context.Context, g *graph.Graph, legs []Leg, limit int, budget *graph.Budget) ([]*Itinerary, graph.SearchStats, error) {
+	if budget == nil {
+		budget = &graph.Budget{}
+	}
+	var stats graph.SearchStats
+
+	type candidate struct {
+		route Route
+		path  *graph.Path
+	}
+
+	remaining := *budget
+	candidates := make([][]candidate, len(legs))
+	for idx := range legs {
+		if budget.MaxStates > 0 && remaining.MaxStates <= 0 {
+			stats.Truncated, stats.Reason = true, graph.ReasonMaxStates
+			return nil, stats, nil
+		}
+		paths, legStats, err := g.GetPathsContext(ctx, legs[idx].Source, legs[idx].Destination, limit, &remaining)
+		stats.Add(legStats)
+		if err != nil {
+			return nil, stats, err
+		}
+		remaining.MaxStates -= legStats.States
+		for p := range paths {
+			route := NewRoute(&paths[p])
+			if legs[idx].departsWithin(route) {
+				candidates[idx] = append(candidates[idx], candidate{route, &paths[p]})
+			}
+		}
+		if len(candidates[idx]) == 0 {
+			return nil, stats, nil
+		}
+	}
+
+	var itineraries []*Itinerary
+	var chosen []candidate
+	var combined int
+	var stop bool
+	var failed error
+
+	var combine func(idx int)
+	combine = func(idx int) {
+		if stop {
+			return
+		}
+		if idx == len(legs) {
+			if budget.MaxPaths > 0 && len(itineraries) >= budget.MaxPaths {
+				stats.Truncated, stats.Reason = true, graph.ReasonMaxPaths
+				stop = true
+				return
+			}
+			itinerary := &Itinerary{}
+			for _, c := range chosen {
+				itinerary.Legs = append(itinerary.Legs, c.route)
+				itinerary.paths = append(itinerary.paths, c.path)
+			}
+			itineraries = append(itineraries, itinerary)
+			return
+		}
+		for _, c := range candidates[idx] {
+			if combined++; combined%combineCheckInterval == 0 {
+				if failed = ctx.Err(); failed != nil {
+					stop = true
+				} else if !budget.Deadline.IsZero() && time.Now().After(budget.Deadline) {
+					stats.Truncated, stats.Reason = true, graph.ReasonDeadline
+					stop = true
+				}
+			}
+			if stop {
+				return
+			}
+			if idx > 0 && !legs[idx-1].allowsStopover(chosen[idx-1].route, c.route) {
+				continue
+			}
+			chosen = append(chosen, c)
+			combine(idx + 1)
+			chosen = chosen[:idx]
+		}
+	}
+	combine(0)
+
+	if failed != nil {
+		return nil, stats, failed
+	}
+	return itineraries, stats, nil
+}
@@ -0,0 +1,324 @@
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Data quality issue codes, input error codes are reported as critical issues as well
+const (
+	QualityDuplicateFlight     = "duplicate_flight"
+	QualityNonPositiveAmount   = "non_positive_amount"
+	QualityNegativeCharge      = "negative_charge"
+	QualityUnknownCurrency     = "unknown_currency"
+	QualityTimeGap             = "time_gap"
+	QualityShortConnection     = "short_connection"
+	QualityAirportMismatch     = "airport_mismatch"
+	QualityDisconnectedAirport = "disconnected_airport"
+)
+
+//MaxQualityIssues limits number of issues listed in QualityReport, counts include all issues
+const MaxQualityIssues = 1000
+
+//QualityMaxLayover is the longest layover between itinerary flights not reported as a time gap
+const QualityMaxLayover = 24 * time.Hour
+
+//QualityIssue is a data quality problem found in AirFareSearchResponse
+type QualityIssue struct {
+	Code      string `json:"code"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Itinerary *int   `json:"itinerary,omitempty"` //index of PricedItineraries Flights element
+	Flight    string `json:"flight,omitempty"`    //flight key
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+
+	leg    string
+	flight int
+}
+
+//AtLeast detects if issue severity is not lower than given level
+func (i *QualityIssue) AtLeast(severity string) bool {
+	return severityRanks[i.Severity] >= severityRanks[severity]
+}
+
+//QualityReport is a data quality report of AirFareSearchResponse
+type QualityReport struct {
+	RequestID   string         `json:"requestId"`
+	Itineraries int            `json:"itineraries"`
+	Flights     int            `json:"flights"`
+	Airports    int            `json:"airports"`
+	Carriers    map[string]int `json:"carriers"`   //flights per carrier
+	Severities  map[string]int `json:"severities"` //issues per severity
+	Codes       map[string]int `json:"codes"`      //issues per code
+	Issues      []QualityIssue `json:"issues"`
+	Truncated   bool           `json:"truncated"`
+}
+
+//Passed detects if report has no issues of given severity or higher
+func (r *QualityReport) Passed(severity string) bool {
+	for level, rank := range severityRanks {
+		if rank >= severityRanks[severity] && r.Severities[level] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *QualityReport) add(issue QualityIssue) {
+	r.Severities[issue.Severity]++
+	r.Codes[issue.Code]++
+	if len(r.Issues) >= MaxQualityIssues {
+		r.Truncated = true
+		return
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+//NewQualityReport checks xml content for data quality issues. Content which can't be decoded is rejected
+//with InputError, inconsistent itineraries are reported as critical issues
+func NewQualityReport(content []byte) (*QualityReport, error) {
+	if err := checkRoot(content); err != nil {
+		return nil, err
+	}
+
+	var data AirFareSearchResponse
+	if err := xml.Unmarshal(content, &data); err != nil {
+		return nil, decodeError(err, content)
+	}
+
+	report := QualityReport{
+		RequestID:   data.RequestID,
+		Itineraries: len(data.PricedItineraries.Flights),
+		Carriers:    make(map[string]int),
+		Severities:  make(map[string]int),
+		Codes:       make(map[string]int),
+		Issues:      []QualityIssue{},
+	}
+
+	offsets := locateItineraries(content)
+	var issues []QualityIssue
+	for idx := range data.PricedItineraries.Flights {
+		f := &data.PricedItineraries.Flights[idx]
+		for _, issue := range validatePricedFlights(f, idx) {
+			quality := QualityIssue{Code: issue.err.Code, Severity: SeverityCritical, Message: issue.err.Message, leg: issue.leg, flight: issue.flight}
+			if flight := legFlight(f, issue.leg, issue.flight); flight != nil {
+				quality.Flight = flight.Key()
+			}
+			issues = append(issues, quality)
+		}
+		issues = append(issues, checkPricing(&f.Pricing)...)
+		issues = append(issues, checkConnections(f)...)
+
+		itinerary := idx
+		for i := range issues {
+			issues[i].Itinerary = &itinerary
+			if idx < len(offsets) {
+				e := InputError{Offset: offsets[idx].offset(issues[i].leg, issues[i].flight)}
+				e.position(content)
+				issues[i].Line, issues[i].Column = e.Line, e.Column
+			}
+			report.add(issues[i])
+		}
+		issues = issues[:0]
+	}
+
+	for _, issue := range checkDuplicates(&data) {
+		report.add(issue)
+	}
+
+	airports := make(map[string]bool)
+	for _, f := range data.PricedItineraries.Flights {
+		for _, items := range []PricedItinerary{f.OnwardPricedItinerary, f.ReturnPricedItinerary} {
+			for _, flight := range items.Flights.Flight {
+				report.Flights++
+				report.Carriers[flight.Carrier.Name]++
+				airports[flight.Source] = true
+				airports[flight.Destination] = true
+			}
+		}
+	}
+	delete(airports, "")
+	report.Airports = len(airports)
+
+	for _, issue := range checkAirports(&data) {
+		report.add(issue)
+	}
+	return &report, nil
+}
+
+//checkPricing reports non positive total amount, negative charges and unknown currency
+func checkPricing(p *Pricing) []QualityIssue {
+	var issues []QualityIssue
+	for _, charge := range p.ServiceCharges {
+		if charge.Amount >= 0 {
+			continue
+		}
+		if charge.ChargeType == "TotalAmount" && charge.Type == "SingleAdult" {
+			continue
+		}
+		issues = append(issues, QualityIssue{Code: QualityNegativeCharge, Severity: SeverityMajor, Message: fmt.Sprintf("%s %s charge is %.2f", charge.Type, charge.ChargeType, charge.Amount)})
+	}
+
+	if amount, ok := p.GetTotalAmount(); ok {
+		switch {
+		case amount < 0:
+			issues = append(issues, QualityIssue{Code: QualityNonPositiveAmount, Severity: SeverityCritical, Message: fmt.Sprintf("total amount is negative: %.2f", amount)})
+		case amount == 0:
+			issues = append(issues, QualityIssue{Code: QualityNonPositiveAmount, Severity: SeverityMajor, Message: "total amount is zero"})
+		}
+	}
+
+	if !Currencies[p.Currency] {
+		message := fmt.Sprintf("unknown currency %q", p.Currency)
+		if p.Currency == "" {
+			message = "no currency"
+		}
+		issues = append(issues, QualityIssue{Code: QualityUnknownCurrency, Severity: SeverityMajor, Message: message})
+	}
+	return issues
+}
+
+//legFlight returns flight idx of onward or return leg, nil for pricing
+func legFlight(f *PricedFlights, leg string, idx int) *Flight {
+	var items *PricedItinerary
+	switch leg {
+	case "onward":
+		items = &f.OnwardPricedItinerary
+	case "return":
+		items = &f.ReturnPricedItinerary
+	default:
+		return nil
+	}
+	if idx >= len(items.Flights.Flight) {
+		return nil
+	}
+	return &items.Flights.Flight[idx]
+}
+
+//checkConnections reports consecutive flights of onward and return legs which don't connect
+func checkConnections(f *PricedFlights) []QualityIssue {
+	var issues []QualityIssue
+	legs := []struct {
+		name  string
+		items *PricedItinerary
+	}{{"onward", &f.OnwardPricedItinerary}, {"return", &f.ReturnPricedItinerary}}
+
+	for _, l := range legs {
+		flights := l.items.Flights.Flight
+		for idx := 1; idx < len(flights); idx++ {
+			prev, next := &flights[idx-1], &flights[idx]
+			add := func(code string, severity string, format string, args ...interface{}) {
+				message := fmt.Sprintf("%s flight %s %s after %s %s: ", l.name, next.Carrier.Name, next.FlightNumber, prev.Carrier.Name, prev.FlightNumber) + fmt.Sprintf(format, args...)
+				issues = append(issues, QualityIssue{Code: code, Severity: severity, Message: message, Flight: next.Key(), leg: l.name, flight: idx})
+			}
+
+			if prev.Destination != "" && next.Source != "" && prev.Destination != next.Source {
+				add(QualityAirportMismatch, SeverityMajor, "departs from %s, previous flight arrives to %s", next.Source, prev.Destination)
+			}
+			if prev.ArrivalTimeStamp.IsZero() || next.DepartureTimeStamp.IsZero() {
+				continue
+			}
+			layover := next.DepartureTimeStamp.Sub(prev.ArrivalTimeStamp.Time)
+			switch {
+			case layover < TransferTimeInMinutes*time.Minute:
+				add(QualityShortConnection, SeverityMajor, "connection of %d minutes, at least %d required", int(layover.Minutes()), TransferTimeInMinutes)
+			case layover > QualityMaxLayover:
+				add(QualityTimeGap, SeverityMinor, "layover of %s", layover)
+			}
+		}
+	}
+	return issues
+}
+
+//checkDuplicates reports flights sharing the same key, major when their prices differ
+func checkDuplicates(data *AirFareSearchResponse) []QualityIssue {
+	list, _ := NewFlightsListWithPolicy(data, &DefaultKeyProfile, DuplicatesKeepAll)
+
+	var issues []QualityIssue
+	for _, collision := range list.Collisions() {
+		severity := SeverityMinor
+		prices := make(map[float32]bool)
+		for _, item := range collision.Flights {
+			price, _ := item.Pricing.GetTotalAmount()
+			prices[price] = true
+		}
+		if len(prices) > 1 {
+			severity = SeverityMajor
+		}
+		issues = append(issues, QualityIssue{
+			Code:     QualityDuplicateFlight,
+			Severity: severity,
+			Message:  fmt.Sprintf("%d flights share the key, %d distinct prices", len(collision.Flights), len(prices)),
+			Flight:   collision.Key,
+		})
+	}
+	return issues
+}
+
+//checkAirports reports airports which aren't connected to the largest group of airports by any flight
+func checkAirports(data *AirFareSearchResponse) []QualityIssue {
+	neighbours := make(map[string][]string)
+	for _, f := range data.PricedItineraries.Flights {
+		for _, items := range []PricedItinerary{f.OnwardPricedItinerary, f.ReturnPricedItinerary} {
+			for _, flight := range items.Flights.Flight {
+				if flight.Source == "" || flight.Destination == "" {
+					continue
+				}
+				neighbours[flight.Source] = append(neighbours[flight.Source], flight.Destination)
+				neighbours[flight.Destination] = append(neighbours[flight.Destination], flight.Source)
+			}
+		}
+	}
+
+	var airports []string
+	for airport := range neighbours {
+		airports = append(airports, airport)
+	}
+	sort.Strings(airports)
+
+	var components [][]string
+	visited := make(map[string]bool)
+	for _, airport := range airports {
+		if visited[airport] {
+			continue
+		}
+		var component []string
+		queue := []string{airport}
+		visited[airport] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component = append(component, current)
+			for _, next := range neighbours[current] {
+				if !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	if len(components) < 2 {
+		return nil
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	var issues []QualityIssue
+	for _, component := range components[1:] {
+		for _, airport := range component {
+			issues = append(issues, QualityIssue{
+				Code:     QualityDisconnectedAirport,
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("%s is in a group of %d airports (%s) not connected to the largest group of %d airports", airport, len(component), strings.Join(component, ", "), len(components[0])),
+			})
+		}
+	}
+	return issues
+}
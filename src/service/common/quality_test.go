@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestQualityReportKeysInvalidFlights(t *testing.T) {
+	content := `<AirFareSearchResponse RequestTime="28-09-2015 20:23:49" ResponseTime="28-09-2015 20:23:56"><RequestId>test</RequestId><PricedItineraries>` +
+		`<Flights><OnwardPricedItinerary><Flights><Flight><Carrier id="AI">AirIndia</Carrier><FlightNumber>996</FlightNumber><Source></Source><Destination>BKK</Destination>` +
+		`<DepartureTimeStamp>2018-10-22T0005</DepartureTimeStamp><ArrivalTimeStamp>2018-10-22T1005</ArrivalTimeStamp><Class>G</Class><NumberOfStops>0</NumberOfStops><FareBasis>GLOW</FareBasis>` +
+		`<WarningText></WarningText><TicketType>E</TicketType></Flight></Flights></OnwardPricedItinerary>` +
+		`<Pricing currency="SGD"><ServiceCharges type="SingleAdult" ChargeType="BaseFare">400</ServiceCharges></Pricing></Flights>` +
+		`</PricedItineraries></AirFareSearchResponse>`
+
+	report, err := NewQualityReport([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make(map[string]string)
+	for _, issue := range report.Issues {
+		codes[issue.Code] = issue.Flight
+	}
+	if flight, exist := codes[CodeMissingSource]; !exist || flight != "AirIndia:996:10-22-2018:GLOW" {
+		t.Errorf("missing source issue of flight %q, want AirIndia:996:10-22-2018:GLOW in %+v", flight, report.Issues)
+	}
+	if flight, exist := codes[CodeMissingTotalAmount]; !exist || flight != "" {
+		t.Errorf("missing total amount issue of flight %q, want pricing issue without flight in %+v", flight, report.Issues)
+	}
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"value": formatValue,
+	"path":  func(path []string) string { return strings.Join(path, ".") },
+	"now":   func() string { return time.Now().UTC().Format(time.RFC1123) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 13px; color: #222; margin: 24px; }
+h1 { font-size: 20px; }
+h2 { font-size: 16px; margin-top: 28px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+.counts span { display: inline-block; margin-right: 16px; padding: 4px 10px; border-radius: 3px; }
+.addition { background: #e6f7e6; }
+.removal { background: #fbe5e5; }
+.update { background: #fff8e1; }
+.schedule_change { background: #e8eefc; }
+.price_decrease { color: #1b7f1b; font-weight: bold; }
+.price_increase { color: #c62828; font-weight: bold; }
+.key { font-family: Menlo, Consolas, monospace; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{now}}</p>
+<div class="counts">
+<span class="addition">Additions: {{.Count "addition"}}</span>
+<span class="removal">Removals: {{.Count "removal"}}</span>
+<span class="update">Updates: {{.Count "update"}}</span>
+<span class="schedule_change">Schedule changes: {{.Count "schedule_change"}}</span>
+</div>
+{{range $kind := .Kinds}}{{if $.Count $kind}}
+<h2>{{$kind}}</h2>
+<table>
+<tr><th>Key</th><th>Field</th><th>Old</th><th>New</th><th>Event</th></tr>
+{{range $.Entries}}{{if eq .Kind $kind}}{{$entry := .}}{{if eq .Kind "update"}}{{$events := .EventsByChange}}{{range $idx, $change := .Changelog}}{{$event := index $events $idx}}
+<tr class="update"><td class="key">{{$entry.Key}}</td><td>{{path $change.Path}}</td><td>{{value $change.From}}</td><td>{{value $change.To}}</td><td{{if $event}} class="{{$event.Type}}"{{end}}>{{if $event}}{{$event.Type}} ({{$event.Severity}}){{end}}</td></tr>
+{{end}}{{else if eq .Kind "schedule_change"}}
+<tr class="schedule_change"><td class="key">{{.Key}}</td><td></td><td class="key">{{.Key}}</td><td class="key">{{.NewKey}}</td><td></td></tr>
+{{else if eq .Kind "addition"}}
+<tr class="addition"><td class="key">{{.Key}}</td><td></td><td></td><td>{{value .Value}}</td><td></td></tr>
+{{else}}
+<tr class="removal"><td class="key">{{.Key}}</td><td></td><td>{{value .Value}}</td><td></td><td></td></tr>
+{{end}}{{end}}{{end}}
+</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+type htmlReport struct {
+	*Report
+	Title string
+}
+
+//Kinds lists entry kinds in report order
+func (r htmlReport) Kinds() []string {
+	return []string{Update, ScheduleChange, Addition, Removal}
+}
+
+//Count returns number of entries of kind
+func (r htmlReport) Count(kind string) int {
+	count := 0
+	for _, entry := range r.Entries {
+		if entry.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+//WriteHTML writes self-contained html report
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, htmlReport{r, "Comparison of " + r.Collection})
+}
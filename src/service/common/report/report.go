@@ -0,0 +1,479 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"service/common"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/r3labs/diff"
+)
+
+//ContentTypes of supported output formats. Plain json is rendered by handlers
+var ContentTypes = map[string]string{
+	"jsonpatch": "application/json-patch+json",
+	"csv":       "text/csv; charset=utf-8",
+	"html":      "text/html; charset=utf-8",
+}
+
+//ParseFormat validates output format. Empty format means json
+func ParseFormat(format string) (string, error) {
+	if format == "" || format == "json" {
+		return "json", nil
+	}
+	if _, exist := ContentTypes[format]; !exist {
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+	return format, nil
+}
+
+//Entry kinds
+const (
+	Addition       = "addition"
+	Removal        = "removal"
+	Update         = "update"
+	ScheduleChange = "schedule_change"
+)
+
+//Entry is a single diff result of flight or route
+type Entry struct {
+	Kind      string
+	Key       string
+	Value     interface{} //FlightItem or Route, old one for updates and schedule changes
+	New       interface{} //new FlightItem of schedule change
+	NewKey    string
+	Changelog diff.Changelog
+	Events    []common.ChangeEvent
+}
+
+//EventsByChange returns entry events by index of changelog entry they were classified from
+func (e Entry) EventsByChange() map[int]*common.ChangeEvent {
+	events := make(map[int]*common.ChangeEvent)
+	for idx := range e.Events {
+		events[e.Events[idx].ChangeIndex()] = &e.Events[idx]
+	}
+	return events
+}
+
+//Report is a diff of flights or routes ready to be rendered
+type Report struct {
+	Collection string //"flights" or "routes", root of normalised document
+	Entries    []Entry
+}
+
+//flightKey returns key flight was matched by in diff
+func flightKey(item common.FlightItem) string {
+	if item.MatchKey != "" {
+		return item.MatchKey
+	}
+	return item.Flight.Key()
+}
+
+//routeKey returns key route was matched by in diff
+func routeKey(route common.Route) string {
+	if route.MatchKey != "" {
+		return route.MatchKey
+	}
+	return route.Key()
+}
+
+//NewFlightsReport creates Report by FlightsList diff results. Entries are keyed by list match keys
+func NewFlightsReport(additions []common.FlightItem, removals []common.FlightItem, updates []common.FlightUpdate, changes []common.ScheduleChange) *Report {
+	r := Report{Collection: "flights"}
+	for _, item := range additions {
+		r.Entries = append(r.Entries, Entry{Kind: Addition, Key: flightKey(item), Value: item})
+	}
+	for _, item := range removals {
+		r.Entries = append(r.Entries, Entry{Kind: Removal, Key: flightKey(item), Value: item})
+	}
+	for _, update := range updates {
+		r.Entries = append(r.Entries, Entry{Kind: Update, Key: flightKey(update.FlightItem), Value: update.FlightItem, Changelog: update.Changelog, Events: update.Events})
+	}
+	for _, change := range changes {
+		r.Entries = append(r.Entries, Entry{Kind: ScheduleChange, Key: flightKey(change.Old), Value: change.Old, NewKey: flightKey(change.New), New: change.New})
+	}
+	r.sort()
+	return &r
+}
+
+//NewRoutesReport creates Report by RoutesList diff results and schedule changes of route flights. Entries are
+//keyed by list match keys
+func NewRoutesReport(additions []common.Route, removals []common.Route, updates []common.RouteUpdate, changes []common.ScheduleChange) *Report {
+	r := Report{Collection: "routes"}
+	for _, route := range additions {
+		r.Entries = append(r.Entries, Entry{Kind: Addition, Key: routeKey(route), Value: route})
+	}
+	for _, route := range removals {
+		r.Entries = append(r.Entries, Entry{Kind: Removal, Key: routeKey(route), Value: route})
+	}
+	for _, update := range updates {
+		r.Entries = append(r.Entries, Entry{Kind: Update, Key: routeKey(update.Route), Value: update.Route, Changelog: update.Changelog, Events: update.Events})
+	}
+	for _, change := range changes {
+		r.Entries = append(r.Entries, Entry{Kind: ScheduleChange, Key: flightKey(change.Old), Value: change.Old, NewKey: flightKey(change.New), New: change.New})
+	}
+	r.sort()
+	return &r
+}
+
+func (r *Report) sort() {
+	sort.SliceStable(r.Entries, func(i, j int) bool {
+		if r.Entries[i].Key != r.Entries[j].Key {
+			return r.Entries[i].Key < r.Entries[j].Key
+		}
+		return r.Entries[i].Kind < r.Entries[j].Kind
+	})
+}
+
+//Write renders report in one of ContentTypes formats
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "jsonpatch":
+		return r.WriteJSONPatch(w)
+	case "csv":
+		return r.WriteCSV(w)
+	case "html":
+		return r.WriteHTML(w)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+//PatchOperation is RFC 6902 operation
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+//MarshalJSON keeps null value of add and replace operations, remove has none
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+//pointer builds RFC 6901 json pointer
+func pointer(tokens ...string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return sb.String()
+}
+
+//documentPath maps changelog path to json document path. Timestamp is marshalled as a plain string
+func documentPath(path []string) []string {
+	if len(path) > 1 && path[len(path)-1] == "Time" {
+		return path[:len(path)-1]
+	}
+	return path
+}
+
+//elementPath returns path of slice element created or deleted by change, the one of the last index. Elements
+//of compared slices don't hold slices by value, their changes are below a single index
+func elementPath(path []string) ([]string, bool) {
+	for idx := len(path) - 1; idx >= 0; idx-- {
+		if _, err := strconv.Atoi(path[idx]); err == nil {
+			return path[:idx+1], true
+		}
+	}
+	return nil, false
+}
+
+//comparePaths orders paths by tokens, indexes are compared as numbers
+func comparePaths(a []string, b []string) int {
+	for idx := 0; idx < len(a) && idx < len(b); idx++ {
+		if a[idx] == b[idx] {
+			continue
+		}
+		x, errX := strconv.Atoi(a[idx])
+		y, errY := strconv.Atoi(b[idx])
+		if errX == nil && errY == nil {
+			return x - y
+		}
+		return strings.Compare(a[idx], b[idx])
+	}
+	return len(a) - len(b)
+}
+
+//elementType returns type of value at changelog path of root type, fields are found by diff tags
+func elementType(root reflect.Type, path []string) reflect.Type {
+	t := root
+	for _, token := range path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Slice:
+			t = t.Elem()
+		case reflect.Struct:
+			var next reflect.Type
+			for idx := 0; idx < t.NumField() && next == nil; idx++ {
+				field := t.Field(idx)
+				name := strings.Split(field.Tag.Get("diff"), ",")[0]
+				if name == "" {
+					name = field.Name
+				}
+				if name == token {
+					next = field.Type
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			t = next
+		default:
+			return nil
+		}
+	}
+	return t
+}
+
+//toDocument converts value to its generic json document
+func toDocument(value interface{}) (interface{}, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	err = json.Unmarshal(content, &document)
+	return document, err
+}
+
+//setDocument sets value at path of json object document
+func setDocument(document interface{}, path []string, value interface{}) bool {
+	for idx, token := range path {
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if idx == len(path)-1 {
+			object[token] = value
+			return true
+		}
+		document = object[token]
+	}
+	return false
+}
+
+//getDocument returns value at path of json document, nil if there is none
+func getDocument(document interface{}, path []string) interface{} {
+	for _, token := range path {
+		switch container := document.(type) {
+		case map[string]interface{}:
+			document = container[token]
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(container) {
+				return nil
+			}
+			document = container[idx]
+		default:
+			return nil
+		}
+	}
+	return document
+}
+
+//element is a slice element created or deleted by changes of update
+type element struct {
+	path    []string
+	changes diff.Changelog
+}
+
+//newElement builds json document of created element. It starts from zero value of element type, created
+//fields are set over it
+func newElement(root reflect.Type, e element) (interface{}, error) {
+	t := elementType(root, e.path)
+	if t == nil {
+		return nil, fmt.Errorf("unknown element %s", strings.Join(e.path, "."))
+	}
+	zero := reflect.New(t).Elem()
+	if t.Kind() == reflect.Ptr {
+		zero = reflect.New(t.Elem())
+	}
+	document, err := toDocument(zero.Interface())
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range e.changes {
+		value, err := toDocument(change.To)
+		if err != nil {
+			return nil, err
+		}
+		path := documentPath(change.Path)[len(e.path):]
+		if len(path) == 0 {
+			document = value
+		} else if !setDocument(document, path, value) {
+			return nil, fmt.Errorf("unknown field %s", strings.Join(change.Path, "."))
+		}
+	}
+	return document, nil
+}
+
+//updatePatch returns operations of update entry. Slice elements are created and deleted as a whole: fields are
+//replaced first, removes go from the highest index down and adds go up from the lowest one, so indexes of the
+//changelog stay valid
+func (r *Report) updatePatch(entry Entry) ([]PatchOperation, error) {
+	var patch []PatchOperation
+	var created, deleted []*element
+	group := func(elements []*element, path []string, change diff.Change) []*element {
+		for _, e := range elements {
+			if comparePaths(e.path, path) == 0 {
+				e.changes = append(e.changes, change)
+				return elements
+			}
+		}
+		return append(elements, &element{path: path, changes: diff.Changelog{change}})
+	}
+	for _, change := range entry.Changelog {
+		path := documentPath(change.Path)
+		at, grouped := elementPath(path)
+		switch {
+		case change.Type == diff.CREATE && grouped:
+			created = group(created, at, change)
+		case change.Type == diff.DELETE && grouped:
+			deleted = group(deleted, at, change)
+		case change.Type == diff.CREATE:
+			patch = append(patch, PatchOperation{Op: "add", Path: pointer(append([]string{r.Collection, entry.Key}, path...)...), Value: change.To})
+		case change.Type == diff.DELETE:
+			patch = append(patch, PatchOperation{Op: "remove", Path: pointer(append([]string{r.Collection, entry.Key}, path...)...)})
+		default:
+			patch = append(patch, PatchOperation{Op: "replace", Path: pointer(append([]string{r.Collection, entry.Key}, path...)...), Value: change.To})
+		}
+	}
+
+	sort.SliceStable(deleted, func(i, j int) bool { return comparePaths(deleted[i].path, deleted[j].path) > 0 })
+	for _, e := range deleted {
+		patch = append(patch, PatchOperation{Op: "remove", Path: pointer(append([]string{r.Collection, entry.Key}, e.path...)...)})
+	}
+	if len(created) == 0 {
+		return patch, nil
+	}
+	//nil slice is marshalled as null, its first element replaces it with an array
+	old, err := toDocument(entry.Value)
+	if err != nil {
+		return nil, err
+	}
+	replaced := make(map[string]bool)
+	sort.SliceStable(created, func(i, j int) bool { return comparePaths(created[i].path, created[j].path) < 0 })
+	for _, e := range created {
+		value, err := newElement(reflect.TypeOf(entry.Value), *e)
+		if err != nil {
+			return nil, err
+		}
+		parent := pointer(append([]string{r.Collection, entry.Key}, e.path[:len(e.path)-1]...)...)
+		if getDocument(old, e.path[:len(e.path)-1]) == nil && !replaced[parent] {
+			replaced[parent] = true
+			patch = append(patch, PatchOperation{Op: "replace", Path: parent, Value: []interface{}{value}})
+			continue
+		}
+		patch = append(patch, PatchOperation{Op: "add", Path: pointer(append([]string{r.Collection, entry.Key}, e.path...)...), Value: value})
+	}
+	return patch, nil
+}
+
+//JSONPatch returns RFC 6902 patch transforming normalised document of the old snapshot into the new one.
+//Normalised document is an object {"<collection>": {"<key>": item}}, schedule changes are in "flights" collection
+func (r *Report) JSONPatch() ([]PatchOperation, error) {
+	var patch []PatchOperation
+	for _, entry := range r.Entries {
+		switch entry.Kind {
+		case Addition:
+			patch = append(patch, PatchOperation{Op: "add", Path: pointer(r.Collection, entry.Key), Value: entry.Value})
+		case Removal:
+			patch = append(patch, PatchOperation{Op: "remove", Path: pointer(r.Collection, entry.Key)})
+		case ScheduleChange:
+			patch = append(patch,
+				PatchOperation{Op: "remove", Path: pointer("flights", entry.Key)},
+				PatchOperation{Op: "add", Path: pointer("flights", entry.NewKey), Value: entry.New},
+			)
+		case Update:
+			operations, err := r.updatePatch(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", entry.Key, err)
+			}
+			patch = append(patch, operations...)
+		}
+	}
+	return patch, nil
+}
+
+//WriteJSONPatch writes RFC 6902 patch
+func (r *Report) WriteJSONPatch(w io.Writer) error {
+	patch, err := r.JSONPatch()
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		patch = []PatchOperation{}
+	}
+	return json.NewEncoder(w).Encode(patch)
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case float32, float64, int, bool:
+		return fmt.Sprint(v)
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(content)
+}
+
+//WriteCSV writes one row per changed field. Additions, removals and schedule changes take a single row
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"change", "key", "field", "old", "new", "event", "severity"}); err != nil {
+		return err
+	}
+
+	for _, entry := range r.Entries {
+		var rows [][]string
+		switch entry.Kind {
+		case Addition:
+			rows = append(rows, []string{entry.Kind, entry.Key, "", "", formatValue(entry.Value), "", ""})
+		case Removal:
+			rows = append(rows, []string{entry.Kind, entry.Key, "", formatValue(entry.Value), "", "", ""})
+		case ScheduleChange:
+			rows = append(rows, []string{entry.Kind, entry.Key, "", entry.Key, entry.NewKey, "", ""})
+		case Update:
+			events := entry.EventsByChange()
+			for idx, change := range entry.Changelog {
+				row := []string{entry.Kind, entry.Key, strings.Join(change.Path, "."), formatValue(change.From), formatValue(change.To), "", ""}
+				if event, exist := events[idx]; exist {
+					row[5], row[6] = event.Type, event.Severity
+				}
+				rows = append(rows, row)
+			}
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,214 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"service/common"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//item returns flight DXB-BKK of number priced at price
+func item(number string, price float32) *common.FlightItem {
+	departure := time.Date(2018, 10, 22, 0, 5, 0, 0, time.UTC)
+	f := &common.Flight{
+		FlightNumber:       number,
+		Source:             "DXB",
+		Destination:        "BKK",
+		DepartureTimeStamp: common.Timestamp{Time: departure},
+		ArrivalTimeStamp:   common.Timestamp{Time: departure.Add(10 * time.Hour)},
+		Class:              "G",
+		FareBasis:          "GLOW",
+		TicketType:         "E",
+	}
+	f.Carrier.ID, f.Carrier.Name = "AI", "AirIndia"
+	return &common.FlightItem{
+		Flight: f,
+		Pricing: &common.Pricing{Currency: "SGD", ServiceCharges: []common.ServiceCharge{
+			{Amount: price - 100, Type: "SingleAdult", ChargeType: "BaseFare"},
+			{Amount: 100, Type: "SingleAdult", ChargeType: "AirlineTaxes"},
+			{Amount: price, Type: "SingleAdult", ChargeType: "TotalAmount"},
+		}},
+	}
+}
+
+//document returns json document of value. Changelog doesn't tell nil slices from empty ones, so empty arrays
+//are normalised to null
+func document(t *testing.T, value interface{}) interface{} {
+	result, err := toDocument(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return normalise(result)
+}
+
+func normalise(document interface{}) interface{} {
+	switch container := document.(type) {
+	case map[string]interface{}:
+		for key, value := range container {
+			container[key] = normalise(value)
+		}
+	case []interface{}:
+		if len(container) == 0 {
+			return nil
+		}
+		for idx, value := range container {
+			container[idx] = normalise(value)
+		}
+	}
+	return document
+}
+
+//apply applies RFC 6902 add, remove and replace operations to json document
+func apply(document interface{}, patch []PatchOperation) (interface{}, error) {
+	for _, operation := range patch {
+		value, err := toDocument(operation.Value)
+		if err != nil {
+			return nil, err
+		}
+		var tokens []string
+		for _, token := range strings.Split(operation.Path, "/")[1:] {
+			tokens = append(tokens, strings.NewReplacer("~1", "/", "~0", "~").Replace(token))
+		}
+		if document, err = applyAt(document, tokens, operation.Op, value); err != nil {
+			return nil, fmt.Errorf("%s %s: %v", operation.Op, operation.Path, err)
+		}
+	}
+	return document, nil
+}
+
+func applyAt(document interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	token := tokens[0]
+	switch container := document.(type) {
+	case map[string]interface{}:
+		current, exist := container[token]
+		if len(tokens) > 1 {
+			if !exist {
+				return nil, fmt.Errorf("no member %q", token)
+			}
+			next, err := applyAt(current, tokens[1:], op, value)
+			container[token] = next
+			return container, err
+		}
+		switch {
+		case op == "add":
+			container[token] = value
+		case !exist:
+			return nil, fmt.Errorf("no member %q", token)
+		case op == "remove":
+			delete(container, token)
+		default:
+			container[token] = value
+		}
+		return container, nil
+	case []interface{}:
+		idx, err := strconv.Atoi(token)
+		if err != nil || idx < 0 || idx > len(container) || (idx == len(container) && (len(tokens) > 1 || op != "add")) {
+			return nil, fmt.Errorf("bad index %q of %d elements", token, len(container))
+		}
+		if len(tokens) > 1 {
+			next, err := applyAt(container[idx], tokens[1:], op, value)
+			container[idx] = next
+			return container, err
+		}
+		switch op {
+		case "add":
+			container = append(container[:idx], append([]interface{}{value}, container[idx:]...)...)
+		case "remove":
+			container = append(container[:idx], container[idx+1:]...)
+		default:
+			container[idx] = value
+		}
+		return container, nil
+	}
+	return nil, fmt.Errorf("%q of scalar", token)
+}
+
+func TestJSONPatchTransformsFlights(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(item *common.FlightItem)
+	}{
+		{"price", func(item *common.FlightItem) { item.Pricing.ServiceCharges[2].Amount += 50 }},
+		{"retimed", func(item *common.FlightItem) {
+			item.Flight.ArrivalTimeStamp.Time = item.Flight.ArrivalTimeStamp.Add(30 * time.Minute)
+		}},
+		{"dropped charges", func(item *common.FlightItem) { item.Pricing.ServiceCharges = item.Pricing.ServiceCharges[2:] }},
+		{"added charges", func(item *common.FlightItem) {
+			item.Pricing.ServiceCharges = append(item.Pricing.ServiceCharges,
+				common.ServiceCharge{Amount: 5, Type: "SingleAdult", ChargeType: "Fee"},
+				common.ServiceCharge{Amount: 0, Type: "SingleChild"})
+		}},
+		{"replaced charges", func(item *common.FlightItem) {
+			charges := item.Pricing.ServiceCharges
+			item.Pricing.ServiceCharges = []common.ServiceCharge{charges[1], {Amount: 7, Type: "SingleAdult", ChargeType: "Fee"}}
+		}},
+		{"no charges", func(item *common.FlightItem) { item.Pricing.ServiceCharges = nil }},
+		{"no pricing", func(item *common.FlightItem) { item.Pricing = nil }},
+	}
+	for _, test := range tests {
+		a, b := item("996", 500), item("996", 500)
+		test.mutate(b)
+		for _, pair := range [][2]*common.FlightItem{{a, b}, {b, a}} {
+			from, to := *pair[0], *pair[1]
+			from.MatchKey, to.MatchKey = "k", "k"
+			update := common.FlightUpdate{FlightItem: from, Changelog: common.DiffFlightItems(&from, &to)}
+			patch, err := NewFlightsReport(nil, nil, []common.FlightUpdate{update}, nil).JSONPatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apply(document(t, map[string]interface{}{"flights": map[string]interface{}{"k": from}}), patch)
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+			if want := document(t, map[string]interface{}{"flights": map[string]interface{}{"k": to}}); !reflect.DeepEqual(normalise(got), want) {
+				content, _ := json.Marshal(patch)
+				t.Errorf("%s: patch %s gives %v, want %v", test.name, content, got, want)
+			}
+		}
+	}
+}
+
+func TestJSONPatchTransformsRoutes(t *testing.T) {
+	route := func(items ...*common.FlightItem) common.Route {
+		return common.Route{Flights: items, MatchKey: "r"}
+	}
+	repriced := item("997", 400)
+	repriced.Pricing.ServiceCharges = repriced.Pricing.ServiceCharges[1:]
+
+	tests := []struct {
+		name string
+		a    common.Route
+		b    common.Route
+	}{
+		{"repriced flight", route(item("996", 500), item("997", 400)), route(item("996", 500), repriced)},
+		{"added flight", route(item("996", 500)), route(item("996", 500), item("997", 400))},
+		{"removed flights", route(item("996", 500), item("997", 400), item("998", 300)), route(item("997", 400))},
+		{"replaced flight", route(item("996", 500), item("997", 400)), route(item("997", 400), item("998", 400))},
+	}
+	for _, test := range tests {
+		for _, pair := range [][2]common.Route{{test.a, test.b}, {test.b, test.a}} {
+			from, to := pair[0], pair[1]
+			update := common.RouteUpdate{Route: from, Changelog: common.DiffRoutes(&from, &to)}
+			patch, err := NewRoutesReport(nil, nil, []common.RouteUpdate{update}, nil).JSONPatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := apply(document(t, map[string]interface{}{"routes": map[string]interface{}{"r": from}}), patch)
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+				continue
+			}
+			if want := document(t, map[string]interface{}{"routes": map[string]interface{}{"r": to}}); !reflect.DeepEqual(normalise(got), want) {
+				content, _ := json.Marshal(patch)
+				t.Errorf("%s: patch %s gives %v, want %v", test.name, content, got, want)
+			}
+		}
+	}
+}
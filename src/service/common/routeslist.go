@@ -1,88 +1,101 @@
 package common
 
 import (
-	"sync"
-
 	"github.com/r3labs/diff"
 )
 
 var RoutesWorkersCount int = 10
 
 type RoutesList struct {
-	routes map[string]*Route
+	routes     map[string]*Route
+	collisions []RouteCollision
 }
 
-//NewRoutesList creates RoutesList by []Route
+//NewRoutesList creates RoutesList by []Route. Duplicates are overwritten by the last one
 func NewRoutesList(routes []Route) *RoutesList {
+	rl, _ := NewRoutesListWithPolicy(routes, DuplicatesOverwrite)
+	return rl
+}
+
+//NewRoutesListWithPolicy creates RoutesList by []Route. Routes sharing the same key are resolved
+//due duplicates policy and reported as collisions
+func NewRoutesListWithPolicy(routes []Route, policy string) (*RoutesList, error) {
+	keys := make([]string, len(routes))
+	for idx := range routes {
+		keys[idx] = routes[idx].Key()
+	}
+
+	kept, collisions, groups, err := resolveDuplicates(keys, func(idx int) (float32, bool) {
+		return routes[idx].GetTotalAmount()
+	}, policy)
+
 	rl := RoutesList{
 		routes: make(map[string]*Route),
 	}
+	for _, key := range collisions {
+		collision := RouteCollision{Key: key}
+		for _, idx := range groups[key] {
+			collision.Routes = append(collision.Routes, routes[idx])
+		}
+		rl.collisions = append(rl.collisions, collision)
+	}
+	if err != nil {
+		return &rl, err
+	}
 
-	for idx, route := range routes {
-		rl.routes[route.Key()] = &routes[idx]
+	for key, idx := range kept {
+		rl.routes[key] = &routes[idx]
 	}
+	return &rl, nil
+}
 
-	return &rl
+//Collisions returns routes sharing the same key
+func (rl *RoutesList) Collisions() []RouteCollision {
+	return rl.collisions
 }
 
 type RouteUpdate struct {
 	Route     Route          `json:"origin"`
 	Changelog diff.Changelog `json:"changes"`
+	Events    []ChangeEvent  `json:"events"`
 }
 
-func (routesB *RoutesList) Diff(routesA *RoutesList) (additions []Route, removals []Route, modifications []RouteUpdate) {
-
-	type comparePair struct {
-		routeA Route
-		routeB Route
+func (rl *RoutesList) keys() []string {
+	keys := make([]string, 0, len(rl.routes))
+	for key := range rl.routes {
+		keys = append(keys, key)
 	}
+	return keys
+}
 
-	jobs := make(chan comparePair)
-	updates := make(chan RouteUpdate)
-
-	wgUpdates := sync.WaitGroup{}
-	wgUpdates.Add(1)
+func (rl *RoutesList) has(key string) bool {
+	_, ok := rl.routes[key]
+	return ok
+}
 
-	go func(updates <-chan RouteUpdate) {
-		defer wgUpdates.Done()
-		for result := range updates {
-			modifications = append(modifications, result)
-		}
-	}(updates)
+func (rl *RoutesList) compare(key string, other keyedList) diff.Changelog {
+	return DiffRoutes(rl.routes[key], other.(*RoutesList).routes[key])
+}
 
-	wgWorkers := sync.WaitGroup{}
-	wgWorkers.Add(RoutesWorkersCount)
+//Diff compares routesA to routesB. Changes ignored by options are dropped, nil options detect any change.
+//Resulting routes carry keys they were matched by
+func (routesB *RoutesList) Diff(routesA *RoutesList, options *CompareOptions) (additions []Route, removals []Route, modifications []RouteUpdate) {
+	added, removed, changed := diffKeyed(routesA, routesB, RoutesWorkersCount, options)
 
-	var worker = func(jobs <-chan comparePair, updates chan<- RouteUpdate) {
-		defer wgWorkers.Done()
-		for compare := range jobs {
-			changelog, _ := diff.Diff(compare.routeA, compare.routeB)
-			if len(changelog) > 0 {
-				updates <- RouteUpdate{compare.routeA, changelog}
-			}
-		}
+	for _, key := range added {
+		route := *routesB.routes[key]
+		route.MatchKey = key
+		additions = append(additions, route)
 	}
-
-	for w := 0; w < RoutesWorkersCount; w++ {
-		go worker(jobs, updates)
+	for _, key := range removed {
+		route := *routesA.routes[key]
+		route.MatchKey = key
+		removals = append(removals, route)
 	}
-
-	for _, routeA := range routesA.routes {
-		if routeB, exist := routesB.routes[routeA.Key()]; exist {
-			jobs <- comparePair{*routeA, *routeB}
-		} else {
-			removals = append(removals, *routeA)
-		}
-	}
-	close(jobs)
-	wgWorkers.Wait()
-	close(updates)
-	wgUpdates.Wait()
-
-	for _, routeB := range routesB.routes {
-		if _, exist := routesA.routes[routeB.Key()]; !exist {
-			additions = append(additions, *routeB)
-		}
+	for _, change := range changed {
+		routeA := *routesA.routes[change.key]
+		routeA.MatchKey = change.key
+		modifications = append(modifications, RouteUpdate{routeA, change.changelog, ClassifyRouteChanges(&routeA, change.changelog)})
 	}
 	return
 }
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"service/common/graph"
+)
+
+//Route stream formats
+const (
+	RouteStreamNDJSON = "ndjson"
+	RouteStreamSSE    = "sse"
+)
+
+//RouteStreamContentTypes are content types of route stream formats
+var RouteStreamContentTypes = map[string]string{
+	RouteStreamNDJSON: "application/x-ndjson",
+	RouteStreamSSE:    "text/event-stream",
+}
+
+//ParseRouteStream validates route stream format. Empty format means no streaming
+func ParseRouteStream(format string) (string, error) {
+	if _, exist := RouteStreamContentTypes[format]; format != "" && !exist {
+		return "", fmt.Errorf("unknown stream format %q", format)
+	}
+	return format, nil
+}
+
+//RouteRecord is a line or event of streaming route search result
+type RouteRecord struct {
+	Type  string             `json:"type"` //route, summary or error
+	Route *Route             `json:"route,omitempty"`
+	Stats *graph.SearchStats `json:"stats,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+//RouteWriter writes RouteRecord as ndjson lines or server-sent events named by record type
+type RouteWriter struct {
+	w      io.Writer
+	format string
+}
+
+//NewRouteWriter creates RouteWriter of stream format
+func NewRouteWriter(w io.Writer, format string) *RouteWriter {
+	return &RouteWriter{w: w, format: format}
+}
+
+//Write writes a single record
+func (rw *RouteWriter) Write(record *RouteRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if rw.format == RouteStreamSSE {
+		_, err = fmt.Fprintf(rw.w, "event: %s\ndata: %s\n\n", record.Type, line)
+		return err
+	}
+	_, err = fmt.Fprintf(rw.w, "%s\n", line)
+	return err
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+//ScheduleChange is a removed flight paired with the added one of the same carrier and flight number
+type ScheduleChange struct {
+	Type                  string     `json:"type"`
+	Old                   FlightItem `json:"old"`
+	New                   FlightItem `json:"new"`
+	OldDeparture          time.Time  `json:"oldDeparture"`
+	NewDeparture          time.Time  `json:"newDeparture"`
+	OldArrival            time.Time  `json:"oldArrival"`
+	NewArrival            time.Time  `json:"newArrival"`
+	DepartureDeltaMinutes int        `json:"departureDeltaMinutes"`
+	ArrivalDeltaMinutes   int        `json:"arrivalDeltaMinutes"`
+	ConnectionsBroken     bool       `json:"connectionsBroken"`
+	BrokenRoutes          []Route    `json:"brokenRoutes,omitempty"`
+}
+
+func isSameService(a *Flight, b *Flight) bool {
+	if a.FlightNumber != b.FlightNumber || a.Source != b.Source || a.Destination != b.Destination {
+		return false
+	}
+	if a.Carrier.ID != "" && b.Carrier.ID != "" {
+		return a.Carrier.ID == b.Carrier.ID
+	}
+	return a.Carrier.Name == b.Carrier.Name
+}
+
+//ReconcileSchedule pairs removals with additions of the same carrier and flight number departing within window.
+//Closest departures are paired first. Unpaired flights are returned as is
+func ReconcileSchedule(additions []FlightItem, removals []FlightItem, window time.Duration) (changes []ScheduleChange, restAdditions []FlightItem, restRemovals []FlightItem) {
+	type candidate struct {
+		removal  int
+		addition int
+		delta    time.Duration
+	}
+
+	var candidates []candidate
+	for r, removal := range removals {
+		for a, addition := range additions {
+			if !isSameService(removal.Flight, addition.Flight) {
+				continue
+			}
+			if addition.Flight.DepartureTimeStamp.Equal(removal.Flight.DepartureTimeStamp.Time) &&
+				addition.Flight.ArrivalTimeStamp.Equal(removal.Flight.ArrivalTimeStamp.Time) {
+				continue
+			}
+			delta := addition.Flight.DepartureTimeStamp.Sub(removal.Flight.DepartureTimeStamp.Time)
+			if delta < 0 {
+				delta = -delta
+			}
+			if delta <= window {
+				candidates = append(candidates, candidate{r, a, delta})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].delta < candidates[j].delta
+	})
+
+	pairedRemovals := make([]bool, len(removals))
+	pairedAdditions := make([]bool, len(additions))
+
+	for _, c := range candidates {
+		if pairedRemovals[c.removal] || pairedAdditions[c.addition] {
+			continue
+		}
+		pairedRemovals[c.removal] = true
+		pairedAdditions[c.addition] = true
+
+		old := removals[c.removal]
+		updated := additions[c.addition]
+		changes = append(changes, ScheduleChange{
+			Type:                  "schedule_change",
+			Old:                   old,
+			New:                   updated,
+			OldDeparture:          old.Flight.DepartureTimeStamp.Time,
+			NewDeparture:          updated.Flight.DepartureTimeStamp.Time,
+			OldArrival:            old.Flight.ArrivalTimeStamp.Time,
+			NewArrival:            updated.Flight.ArrivalTimeStamp.Time,
+			DepartureDeltaMinutes: int(updated.Flight.DepartureTimeStamp.Sub(old.Flight.DepartureTimeStamp.Time) / time.Minute),
+			ArrivalDeltaMinutes:   int(updated.Flight.ArrivalTimeStamp.Sub(old.Flight.ArrivalTimeStamp.Time) / time.Minute),
+		})
+	}
+
+	for idx, addition := range additions {
+		if !pairedAdditions[idx] {
+			restAdditions = append(restAdditions, addition)
+		}
+	}
+	for idx, removal := range removals {
+		if !pairedRemovals[idx] {
+			restRemovals = append(restRemovals, removal)
+		}
+	}
+	return
+}
+
+//CheckConnections marks schedule changes which break connections of routes built on the old flight
+func CheckConnections(changes []ScheduleChange, routes []Route) {
+	for idx := range changes {
+		change := &changes[idx]
+		oldKey := change.Old.Flight.Key()
+
+		for _, route := range routes {
+			for pos, item := range route.Flights {
+				if item.Flight.Key() != oldKey {
+					continue
+				}
+				broken := pos > 0 && !change.New.IsAccessibleFrom(route.Flights[pos-1])
+				broken = broken || pos < len(route.Flights)-1 && !route.Flights[pos+1].IsAccessibleFrom(&change.New)
+				if broken {
+					change.ConnectionsBroken = true
+					change.BrokenRoutes = append(change.BrokenRoutes, route)
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"os"
+	"service/common/graph"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//SearchLimits bound route search of a single request
+type SearchLimits struct {
+	MaxStates  int
+	MaxRoutes  int
+	Timeout    time.Duration
+	MaxLayover time.Duration //zero is unlimited
+}
+
+var (
+	defaultSearchLimits, maxSearchLimits SearchLimits
+	searchLimitsOnce                     sync.Once
+)
+
+func envInt(name string, value int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return value
+}
+
+func envDuration(name string, value time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return value
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+//loadSearchLimits reads defaults from env SEARCH_MAX_STATES, SEARCH_MAX_ROUTES, SEARCH_TIMEOUT and caps of
+//request overrides from SEARCH_MAX_STATES_CAP, SEARCH_MAX_ROUTES_CAP, SEARCH_TIMEOUT_CAP. Defaults don't exceed caps.
+//SEARCH_MAX_LAYOVER is a default of layover limit, unlimited if unset, and has no cap
+func loadSearchLimits() {
+	searchLimitsOnce.Do(func() {
+		maxSearchLimits = SearchLimits{
+			MaxStates: envInt("SEARCH_MAX_STATES_CAP", 10000000),
+			MaxRoutes: envInt("SEARCH_MAX_ROUTES_CAP", 100000),
+			Timeout:   envDuration("SEARCH_TIMEOUT_CAP", 25*time.Second),
+		}
+		defaultSearchLimits = SearchLimits{
+			MaxStates:  minInt(envInt("SEARCH_MAX_STATES", 1000000), maxSearchLimits.MaxStates),
+			MaxRoutes:  minInt(envInt("SEARCH_MAX_ROUTES", 10000), maxSearchLimits.MaxRoutes),
+			Timeout:    envDuration("SEARCH_TIMEOUT", 10*time.Second),
+			MaxLayover: envDuration("SEARCH_MAX_LAYOVER", 0),
+		}
+		if defaultSearchLimits.Timeout > maxSearchLimits.Timeout {
+			defaultSearchLimits.Timeout = maxSearchLimits.Timeout
+		}
+	})
+}
+
+//DefaultSearchLimits returns server defaults of route search limits
+func DefaultSearchLimits() SearchLimits {
+	loadSearchLimits()
+	return defaultSearchLimits
+}
+
+//Budget returns graph.Budget of limits with deadline counted from now
+func (l SearchLimits) Budget() *graph.Budget {
+	return &graph.Budget{
+		MaxStates:  l.MaxStates,
+		MaxPaths:   l.MaxRoutes,
+		Deadline:   time.Now().Add(l.Timeout),
+		MaxLayover: l.MaxLayover,
+	}
+}
+
+//SearchBudgetRequest is a multipart/form-data binding of SearchLimits. Unset or non positive fields keep
+//server defaults, greater than server caps are lowered to caps
+type SearchBudgetRequest struct {
+	MaxStates  int `form:"max_states"`
+	MaxRoutes  int `form:"max_routes"`
+	Timeout    int `form:"timeout"`     //milliseconds
+	MaxLayover int `form:"max_layover"` //minutes
+}
+
+//Limits returns server defaults overridden by request
+func (r *SearchBudgetRequest) Limits() SearchLimits {
+	loadSearchLimits()
+	limits := defaultSearchLimits
+	if r.MaxStates > 0 {
+		limits.MaxStates = minInt(r.MaxStates, maxSearchLimits.MaxStates)
+	}
+	if r.MaxRoutes > 0 {
+		limits.MaxRoutes = minInt(r.MaxRoutes, maxSearchLimits.MaxRoutes)
+	}
+	if timeout := time.Duration(r.Timeout) * time.Millisecond; timeout > 0 {
+		limits.Timeout = timeout
+		if timeout > maxSearchLimits.Timeout {
+			limits.Timeout = maxSearchLimits.Timeout
+		}
+	}
+	if r.MaxLayover > 0 {
+		limits.MaxLayover = time.Duration(r.MaxLayover) * time.Minute
+	}
+	return limits
+}
+
+//Budget returns graph.Budget of request limits with deadline counted from now
+func (r *SearchBudgetRequest) Budget() *graph.Budget {
+	return r.Limits().Budget()
+}
@@ -0,0 +1,187 @@
+package common
+
+import (
+	"fmt"
+	"service/common/store"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SnapshotsCollection is a store collection of snapshot series
+const SnapshotsCollection = "series"
+
+//ParseRequestTime parses AirFareSearchResponse RequestTime, "28-09-2015 20:23:49"
+func ParseRequestTime(value string) (time.Time, error) {
+	for _, format := range []string{"02-01-2006 15:04:05", time.RFC3339} {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported RequestTime %q", value)
+}
+
+//Snapshot is a stored dataset taken at RequestTime
+type Snapshot struct {
+	DatasetID   string    `json:"datasetId"`
+	RequestTime time.Time `json:"requestTime"`
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+//Series is an ordered by RequestTime list of snapshots of the same search
+type Series struct {
+	Name      string     `json:"name"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+//LoadSeries reads series from store. Missing series is returned empty
+func LoadSeries(s *store.Store, name string) (*Series, error) {
+	series := Series{Name: name}
+	err := s.LoadDocument(SnapshotsCollection, name, &series)
+	if err == store.ErrNotFound {
+		return &series, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+//Add puts snapshot into series keeping RequestTime order. Snapshot with the same RequestTime is replaced.
+//Returns true when snapshot is new to the series and became its latest entry, false for re-submits and backfills
+func (series *Series) Add(snapshot Snapshot) bool {
+	for idx := range series.Snapshots {
+		if series.Snapshots[idx].RequestTime.Equal(snapshot.RequestTime) {
+			series.Snapshots[idx] = snapshot
+			return false
+		}
+	}
+	series.Snapshots = append(series.Snapshots, snapshot)
+	sort.SliceStable(series.Snapshots, func(i, j int) bool {
+		return series.Snapshots[i].RequestTime.Before(series.Snapshots[j].RequestTime)
+	})
+	return series.Snapshots[len(series.Snapshots)-1].RequestTime.Equal(snapshot.RequestTime)
+}
+
+var seriesMu sync.Mutex
+
+//AddSnapshot loads series, puts snapshot into it and saves series back. Reports whether snapshot is new to
+//the series and became its latest entry, see Series.Add
+func AddSnapshot(s *store.Store, name string, snapshot Snapshot) (*Series, bool, error) {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+
+	series, err := LoadSeries(s, name)
+	if err != nil {
+		return nil, false, err
+	}
+	latest := series.Add(snapshot)
+	if err := s.SaveDocument(SnapshotsCollection, name, series); err != nil {
+		return nil, false, err
+	}
+	return series, latest, nil
+}
+
+//DatasetInUseError is returned when a dataset to delete is a snapshot of series
+type DatasetInUseError struct {
+	Series []string
+}
+
+func (e *DatasetInUseError) Error() string {
+	return fmt.Sprintf("dataset is a snapshot of series %s", strings.Join(e.Series, ", "))
+}
+
+//DeleteDataset removes dataset from store unless it is a snapshot of any series
+func DeleteDataset(s *store.Store, id string) error {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+
+	names, err := s.ListDocuments(SnapshotsCollection)
+	if err != nil {
+		return err
+	}
+
+	var referencing []string
+	for _, name := range names {
+		series, err := LoadSeries(s, name)
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range series.Snapshots {
+			if snapshot.DatasetID == id {
+				referencing = append(referencing, name)
+				break
+			}
+		}
+	}
+	if len(referencing) > 0 {
+		return &DatasetInUseError{referencing}
+	}
+	return s.Delete(id)
+}
+
+//PricePoint is flight price and availability in a snapshot
+type PricePoint struct {
+	Time      time.Time `json:"time"`
+	Available bool      `json:"available"`
+	Price     *float32  `json:"price,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+}
+
+//FlightHistory is flight price and availability over series of snapshots
+type FlightHistory struct {
+	Key         string       `json:"key"`
+	Flight      *Flight      `json:"flight"`
+	Points      []PricePoint `json:"points"`
+	FirstSeen   time.Time    `json:"firstSeen"`
+	LastSeen    time.Time    `json:"lastSeen"`
+	MinPrice    *float32     `json:"minPrice,omitempty"`
+	MaxPrice    *float32     `json:"maxPrice,omitempty"`
+	LatestPrice *float32     `json:"latestPrice,omitempty"`
+}
+
+//NewHistory builds history of every flight by RequestTime ordered snapshots data. Flights are keyed by
+//Flight.Key, a flight offered several times in a snapshot is priced by its cheapest offer
+func NewHistory(times []time.Time, snapshots []*AirFareSearchResponse) map[string]*FlightHistory {
+	history := make(map[string]*FlightHistory)
+
+	lists := make([]*FlightsList, len(snapshots))
+	for idx, data := range snapshots {
+		lists[idx], _ = NewFlightsListWithPolicy(data, &DefaultKeyProfile, DuplicatesKeepCheapest)
+		for key, item := range lists[idx].flightItems {
+			if _, exist := history[key]; !exist {
+				history[key] = &FlightHistory{Key: key, Flight: item.Flight}
+			}
+		}
+	}
+
+	for key, h := range history {
+		for idx, list := range lists {
+			point := PricePoint{Time: times[idx]}
+			item, exist := list.flightItems[key]
+			if exist {
+				point.Available = true
+				h.Flight = item.Flight
+				if h.FirstSeen.IsZero() {
+					h.FirstSeen = times[idx]
+				}
+				h.LastSeen = times[idx]
+
+				if price, ok := item.Pricing.GetTotalAmount(); ok {
+					point.Price = &price
+					point.Currency = item.Pricing.Currency
+					if h.MinPrice == nil || price < *h.MinPrice {
+						h.MinPrice = &price
+					}
+					if h.MaxPrice == nil || price > *h.MaxPrice {
+						h.MaxPrice = &price
+					}
+					h.LatestPrice = &price
+				}
+			}
+			h.Points = append(h.Points, point)
+		}
+	}
+	return history
+}
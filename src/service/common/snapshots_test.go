@@ -0,0 +1,52 @@
+package common
+
+import (
+	"service/common/store"
+	"testing"
+	"time"
+)
+
+func TestAddSnapshotReportsLatest(t *testing.T) {
+	s, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour int, id string) Snapshot {
+		return Snapshot{DatasetID: id, RequestTime: time.Date(2015, 9, 28, hour, 0, 0, 0, time.UTC)}
+	}
+
+	tests := []struct {
+		name     string
+		snapshot Snapshot
+		latest   bool
+		count    int
+	}{
+		{"first", at(10, "a"), true, 1},
+		{"next", at(12, "b"), true, 2},
+		{"re-submit of latest", at(12, "c"), false, 2},
+		{"backfill", at(11, "d"), false, 3},
+		{"re-submit of backfill", at(11, "e"), false, 3},
+		{"next after backfill", at(13, "f"), true, 4},
+	}
+	for _, test := range tests {
+		series, latest, err := AddSnapshot(s, "DXB-BKK", test.snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest != test.latest || len(series.Snapshots) != test.count {
+			t.Errorf("%s: latest %t of %d snapshots, want %t of %d", test.name, latest, len(series.Snapshots), test.latest, test.count)
+		}
+	}
+
+	series, err := LoadSeries(s, "DXB-BKK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids string
+	for _, snapshot := range series.Snapshots {
+		ids += snapshot.DatasetID
+	}
+	if ids != "aecf" {
+		t.Errorf("series snapshots %q, want %q", ids, "aecf")
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//ErrInvalidName is returned when document name or collection contains unsupported characters
+var ErrInvalidName = errors.New("store: invalid name")
+
+var namePattern = regexp.MustCompile("^[A-Za-z0-9_.-]{1,128}$")
+
+func (s *Store) documentPath(collection string, name string) (string, error) {
+	if !namePattern.MatchString(collection) || !namePattern.MatchString(name) || strings.HasPrefix(name, ".") {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(s.dir, collection, name+".json"), nil
+}
+
+//SaveDocument stores v as json document in collection. Existing document is replaced
+func (s *Store) SaveDocument(collection string, name string, v interface{}) error {
+	path, err := s.documentPath(collection, name)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+name)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+//LoadDocument reads json document from collection into v
+func (s *Store) LoadDocument(collection string, name string, v interface{}) error {
+	path, err := s.documentPath(collection, name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+//DeleteDocument removes json document from collection
+func (s *Store) DeleteDocument(collection string, name string) error {
+	path, err := s.documentPath(collection, name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+//ListDocuments returns sorted names of documents in collection
+func (s *Store) ListDocuments(collection string) ([]string, error) {
+	if !namePattern.MatchString(collection) {
+		return nil, ErrInvalidName
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, collection))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		name := f.Name()
+		if !f.IsDir() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".json") {
+			names = append(names, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
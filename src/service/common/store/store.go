@@ -0,0 +1,149 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+//ErrNotFound is returned when there is no item with given id
+var ErrNotFound = errors.New("store: item not found")
+
+//ErrInvalidID is returned when id is not a content hash
+var ErrInvalidID = errors.New("store: invalid id")
+
+var idPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+//Store is a content-addressed on-disk storage. Items are kept as files named by sha256 of content
+type Store struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+//Open creates Store in dir. Dir is created if it doesn't exist
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+var (
+	defaultStore    *Store
+	defaultStoreErr error
+	defaultOnce     sync.Once
+)
+
+//Default returns Store located in env DATASTORE_DIR, "data" by default
+func Default() (*Store, error) {
+	defaultOnce.Do(func() {
+		dir := os.Getenv("DATASTORE_DIR")
+		if dir == "" {
+			dir = "data"
+		}
+		defaultStore, defaultStoreErr = Open(dir)
+	})
+	return defaultStore, defaultStoreErr
+}
+
+//ID returns content hash used as item id
+func ID(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) path(id string) (string, error) {
+	if !idPattern.MatchString(id) {
+		return "", ErrInvalidID
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+//Put saves content and returns its id. Saving the same content twice is a no-op
+func (s *Store) Put(content []byte) (string, error) {
+	id := ID(content)
+	path, _ := s.path(id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return id, nil
+	}
+
+	tmp, err := ioutil.TempFile(s.dir, id+".tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return id, nil
+}
+
+//Get returns content stored by id
+func (s *Store) Get(id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
+
+//Reader opens content stored by id for reading without loading it into memory
+func (s *Store) Reader(id string) (io.ReadCloser, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+//Delete removes content stored by id
+func (s *Store) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,263 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+//DefaultStreamPartitions number of partitions of streaming comparison, COMPARE_STREAM_PARTITIONS overrides it.
+//Memory used by comparison is about size of both snapshots divided by partitions number
+const DefaultStreamPartitions = 64
+
+func streamPartitions() int {
+	if n, err := strconv.Atoi(os.Getenv("COMPARE_STREAM_PARTITIONS")); err == nil && n > 0 {
+		return n
+	}
+	return DefaultStreamPartitions
+}
+
+//ScanPricedFlights decodes AirFareSearchResponse itineraries one by one, so the whole document
+//is never kept in memory. Itineraries are validated as ParseAirFareSearchResponse does, errors
+//have byte offsets only
+func ScanPricedFlights(r io.Reader, fn func(f *PricedFlights) error) error {
+	d := xml.NewDecoder(r)
+	var path []string
+	itinerary := 0
+	root, text := false, false
+	for {
+		offset := d.InputOffset()
+		token, err := d.Token()
+		switch {
+		case err == io.EOF && root:
+			return nil
+		case err == io.EOF && !text:
+			return &InputError{Code: CodeEmptyContent, Message: "empty content"}
+		case err != nil:
+			return decodeError(err, nil)
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			text = text || len(bytes.TrimSpace(t)) > 0
+		case xml.StartElement:
+			root = true
+			if len(path) == 0 && t.Name.Local != "AirFareSearchResponse" {
+				return &InputError{Code: CodeUnexpectedRoot, Message: fmt.Sprintf("unexpected root element %s", t.Name.Local), Offset: offset + 1}
+			}
+			if len(path) == 2 && path[1] == "PricedItineraries" && t.Name.Local == "Flights" {
+				var f PricedFlights
+				if err := d.DecodeElement(&f, &t); err != nil {
+					return decodeError(err, nil)
+				}
+				if issues := validatePricedFlights(&f, itinerary); len(issues) > 0 {
+					issues[0].err.Offset = offset + 1
+					return issues[0].err
+				}
+				itinerary++
+				if err := fn(&f); err != nil {
+					return err
+				}
+				continue
+			}
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+}
+
+//partitionRecord is a flight stored in partition file
+type partitionRecord struct {
+	Key     string
+	Flight  Flight
+	Pricing Pricing
+}
+
+func partitionOf(key string, partitions int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(partitions))
+}
+
+//StreamRecord is a line of streaming comparison result
+type StreamRecord struct {
+	Type      string           `json:"type"` //addition, removal, update, collision, error or summary
+	Side      string           `json:"side,omitempty"`
+	Flight    *FlightItem      `json:"flight,omitempty"`
+	Update    *FlightUpdate    `json:"update,omitempty"`
+	Collision *FlightCollision `json:"collision,omitempty"`
+	Counts    *ChangeCounts    `json:"counts,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+//StreamCompare compares two snapshots hash-partitioned on disk by flight keys, one partition at a time
+type StreamCompare struct {
+	dir        string
+	partitions int
+	profile    *KeyProfile
+	policy     string
+}
+
+//NewStreamCompare creates comparison of snapshots keyed by profile with partitions in temporary directory.
+//Both snapshots should be partitioned before Run, Close removes partitions
+func NewStreamCompare(profile *KeyProfile, policy string) (*StreamCompare, error) {
+	dir, err := ioutil.TempDir("", "compare")
+	if err != nil {
+		return nil, err
+	}
+	return &StreamCompare{dir: dir, partitions: streamPartitions(), profile: profile, policy: policy}, nil
+}
+
+func (sc *StreamCompare) path(side string, partition int) string {
+	return filepath.Join(sc.dir, fmt.Sprintf("%s-%d", side, partition))
+}
+
+//Partition splits snapshot of side a or b into partition files by flight keys
+func (sc *StreamCompare) Partition(side string, r io.Reader) error {
+	files := make([]*os.File, sc.partitions)
+	writers := make([]*bufio.Writer, sc.partitions)
+	encoders := make([]*gob.Encoder, sc.partitions)
+	defer func() {
+		for _, file := range files {
+			if file != nil {
+				file.Close()
+			}
+		}
+	}()
+
+	for idx := range files {
+		file, err := os.Create(sc.path(side, idx))
+		if err != nil {
+			return err
+		}
+		files[idx] = file
+		writers[idx] = bufio.NewWriter(file)
+		encoders[idx] = gob.NewEncoder(writers[idx])
+	}
+
+	err := ScanPricedFlights(r, func(f *PricedFlights) error {
+		for _, itinerary := range []*PricedItinerary{&f.OnwardPricedItinerary, &f.ReturnPricedItinerary} {
+			for idx := range itinerary.Flights.Flight {
+				record := partitionRecord{sc.profile.Key(&itinerary.Flights.Flight[idx]), itinerary.Flights.Flight[idx], f.Pricing}
+				if err := encoders[partitionOf(record.Key, sc.partitions)].Encode(&record); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sc *StreamCompare) load(side string, partition int) (*FlightsList, error) {
+	file, err := os.Open(sc.path(side, partition))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []string
+	var items []FlightItem
+	d := gob.NewDecoder(bufio.NewReader(file))
+	for {
+		var record partitionRecord
+		if err := d.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		keys = append(keys, record.Key)
+		items = append(items, FlightItem{Flight: &record.Flight, Pricing: &record.Pricing})
+	}
+	return newFlightsList(keys, items, sc.policy)
+}
+
+//Run compares partitions one by one and emits results, summary is emitted last. Updates with events
+//lower than severity are dropped. Comparison is abandoned with ctx error when ctx is done, errors of
+//loading partitions, duplicate keys of fail policy and emit are returned without summary
+func (sc *StreamCompare) Run(ctx context.Context, options *CompareOptions, severity string, emit func(record *StreamRecord) error) error {
+	counts := ChangeCounts{Events: make(map[string]int)}
+	send := func(record *StreamRecord) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return emit(record)
+	}
+
+	for partition := 0; partition < sc.partitions; partition++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		flightsA, errA := sc.load("a", partition)
+		if flightsA == nil {
+			return errA
+		}
+		flightsB, errB := sc.load("b", partition)
+		if flightsB == nil {
+			return errB
+		}
+
+		for side, list := range []*FlightsList{flightsA, flightsB} {
+			for idx := range list.collisions {
+				if err := send(&StreamRecord{Type: "collision", Side: []string{"a", "b"}[side], Collision: &list.collisions[idx]}); err != nil {
+					return err
+				}
+			}
+		}
+		if err := errA; err != nil || errB != nil {
+			if err == nil {
+				err = errB
+			}
+			return err
+		}
+
+		additions, removals, updates := flightsB.Diff(flightsA, options)
+		updates = FilterFlightUpdates(updates, severity)
+
+		for idx := range additions {
+			if err := send(&StreamRecord{Type: "addition", Flight: &additions[idx]}); err != nil {
+				return err
+			}
+		}
+		for idx := range removals {
+			if err := send(&StreamRecord{Type: "removal", Flight: &removals[idx]}); err != nil {
+				return err
+			}
+		}
+		for idx := range updates {
+			if err := send(&StreamRecord{Type: "update", Update: &updates[idx]}); err != nil {
+				return err
+			}
+			counts.addEvents(updates[idx].Events)
+		}
+		counts.Additions += len(additions)
+		counts.Removals += len(removals)
+		counts.Updates += len(updates)
+	}
+
+	return send(&StreamRecord{Type: "summary", Counts: &counts})
+}
+
+//Close removes partitions from disk
+func (sc *StreamCompare) Close() error {
+	return os.RemoveAll(sc.dir)
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"sort"
+	"strings"
+)
+
+//BiggestMoversCount number of flights with the largest price movement reported in summary
+var BiggestMoversCount = 10
+
+//ChangeCounts counts of diff items by change type
+type ChangeCounts struct {
+	Additions       int            `json:"additions"`
+	Removals        int            `json:"removals"`
+	Updates         int            `json:"updates"`
+	ScheduleChanges int            `json:"scheduleChanges"`
+	Events          map[string]int `json:"events"`
+}
+
+func (c *ChangeCounts) addEvents(events []ChangeEvent) {
+	for _, event := range events {
+		c.Events[event.Type]++
+	}
+}
+
+//PriceMovement aggregates total amount changes of updated flights
+type PriceMovement struct {
+	Count          int     `json:"count"`
+	Increases      int     `json:"increases"`
+	Decreases      int     `json:"decreases"`
+	Average        float32 `json:"average"`
+	Median         float32 `json:"median"`
+	AveragePercent float32 `json:"averagePercent"`
+	MedianPercent  float32 `json:"medianPercent"`
+}
+
+//Mover is a flight with its total amount change. Key is the match key flight was matched by
+type Mover struct {
+	Key          string   `json:"key"`
+	Flight       *Flight  `json:"flight"`
+	Old          float32  `json:"old"`
+	New          float32  `json:"new"`
+	Delta        float32  `json:"delta"`
+	DeltaPercent *float32 `json:"deltaPercent,omitempty"`
+}
+
+//DiffSummary is an overview of FlightsList diff
+type DiffSummary struct {
+	Counts        *ChangeCounts            `json:"counts"`
+	ByCarrier     map[string]*ChangeCounts `json:"byCarrier"`
+	ByMarket      map[string]*ChangeCounts `json:"byMarket"`
+	ByClass       map[string]*ChangeCounts `json:"byClass"`
+	PriceMovement PriceMovement            `json:"priceMovement"`
+	BiggestMovers []Mover                  `json:"biggestMovers"`
+}
+
+func newChangeCounts() *ChangeCounts {
+	return &ChangeCounts{Events: make(map[string]int)}
+}
+
+//groups returns counts of every group flight belongs to
+func (s *DiffSummary) groups(f *Flight) []*ChangeCounts {
+	var groups []*ChangeCounts
+	for _, g := range []struct {
+		counts map[string]*ChangeCounts
+		key    string
+	}{
+		{s.ByCarrier, f.Carrier.Name},
+		{s.ByMarket, f.Source + "-" + f.Destination},
+		{s.ByClass, f.Class},
+	} {
+		counts, exist := g.counts[g.key]
+		if !exist {
+			counts = newChangeCounts()
+			g.counts[g.key] = counts
+		}
+		groups = append(groups, counts)
+	}
+	return append(groups, s.Counts)
+}
+
+//NewDiffSummary aggregates FlightsList diff results
+func NewDiffSummary(additions []FlightItem, removals []FlightItem, updates []FlightUpdate, changes []ScheduleChange) *DiffSummary {
+	s := DiffSummary{
+		Counts:    newChangeCounts(),
+		ByCarrier: make(map[string]*ChangeCounts),
+		ByMarket:  make(map[string]*ChangeCounts),
+		ByClass:   make(map[string]*ChangeCounts),
+	}
+
+	for _, item := range additions {
+		for _, counts := range s.groups(item.Flight) {
+			counts.Additions++
+		}
+	}
+	for _, item := range removals {
+		for _, counts := range s.groups(item.Flight) {
+			counts.Removals++
+		}
+	}
+	for _, change := range changes {
+		for _, counts := range s.groups(change.New.Flight) {
+			counts.ScheduleChanges++
+		}
+	}
+
+	var movers []Mover
+	priced := make(map[*Pricing]bool) //flights of an itinerary share its pricing, its changes are counted once
+	for _, update := range updates {
+		events := update.Events
+		if pricing := update.FlightItem.Pricing; pricing != nil {
+			if priced[pricing] {
+				events = withoutPricing(events)
+			}
+			priced[pricing] = true
+		}
+
+		for _, counts := range s.groups(update.FlightItem.Flight) {
+			counts.Updates++
+			counts.addEvents(events)
+		}
+
+		for _, event := range events {
+			if event.Field != "pricing.TotalAmount" || event.Delta == nil {
+				continue
+			}
+			movers = append(movers, Mover{
+				Key:          update.FlightItem.MatchKey,
+				Flight:       update.FlightItem.Flight,
+				Old:          event.Old.(float32),
+				New:          event.New.(float32),
+				Delta:        *event.Delta,
+				DeltaPercent: event.DeltaPercent,
+			})
+		}
+	}
+
+	s.PriceMovement = newPriceMovement(movers)
+
+	sort.SliceStable(movers, func(i, j int) bool {
+		return abs32(movers[i].Delta) > abs32(movers[j].Delta)
+	})
+	if len(movers) > BiggestMoversCount {
+		movers = movers[:BiggestMoversCount]
+	}
+	s.BiggestMovers = movers
+
+	return &s
+}
+
+//withoutPricing returns events of fields other than pricing
+func withoutPricing(events []ChangeEvent) []ChangeEvent {
+	var result []ChangeEvent
+	for _, event := range events {
+		if !strings.HasPrefix(event.Field, "pricing") {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func newPriceMovement(movers []Mover) PriceMovement {
+	movement := PriceMovement{Count: len(movers)}
+	if len(movers) == 0 {
+		return movement
+	}
+
+	var deltas, percents []float32
+	var sum, sumPercent float32
+	for _, m := range movers {
+		if m.Delta > 0 {
+			movement.Increases++
+		} else if m.Delta < 0 {
+			movement.Decreases++
+		}
+		deltas = append(deltas, m.Delta)
+		sum += m.Delta
+		if m.DeltaPercent != nil {
+			percents = append(percents, *m.DeltaPercent)
+			sumPercent += *m.DeltaPercent
+		}
+	}
+
+	movement.Average = sum / float32(len(deltas))
+	movement.Median = median(deltas)
+	if len(percents) > 0 {
+		movement.AveragePercent = sumPercent / float32(len(percents))
+		movement.MedianPercent = median(percents)
+	}
+	return movement
+}
+
+func median(values []float32) float32 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
+
+func abs32(value float32) float32 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+//itinerary returns data of a single DXB-DEL-BKK itinerary priced at price
+func itinerary(t *testing.T, price int) *AirFareSearchResponse {
+	flight := func(number string, source string, destination string, departure string, arrival string) string {
+		return fmt.Sprintf(`<Flight><Carrier id="AI">AirIndia</Carrier><FlightNumber>%s</FlightNumber><Source>%s</Source><Destination>%s</Destination>`+
+			`<DepartureTimeStamp>%s</DepartureTimeStamp><ArrivalTimeStamp>%s</ArrivalTimeStamp><Class>G</Class><NumberOfStops>0</NumberOfStops><FareBasis>GLOW</FareBasis>`+
+			`<WarningText></WarningText><TicketType>E</TicketType></Flight>`, number, source, destination, departure, arrival)
+	}
+	content := `<AirFareSearchResponse RequestTime="28-09-2015 20:23:49" ResponseTime="28-09-2015 20:23:56"><RequestId>test</RequestId><PricedItineraries>` +
+		`<Flights><OnwardPricedItinerary><Flights>` +
+		flight("996", "DXB", "DEL", "2018-10-22T0005", "2018-10-22T0405") +
+		flight("332", "DEL", "BKK", "2018-10-22T0600", "2018-10-22T1100") +
+		`</Flights></OnwardPricedItinerary>` +
+		fmt.Sprintf(`<Pricing currency="SGD"><ServiceCharges type="SingleAdult" ChargeType="TotalAmount">%d</ServiceCharges></Pricing></Flights>`, price) +
+		`</PricedItineraries></AirFareSearchResponse>`
+
+	data, err := ParseAirFareSearchResponse([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDiffSummaryCountsItineraryPriceOnce(t *testing.T) {
+	profile, err := ParseKeyProfile("", "carrier_id,flight_number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listA, _ := NewFlightsListWithPolicy(itinerary(t, 500), profile, DuplicatesKeepAll)
+	listB, _ := NewFlightsListWithPolicy(itinerary(t, 600), profile, DuplicatesKeepAll)
+	additions, removals, updates := listB.Diff(listA, nil)
+	if len(updates) != 2 {
+		t.Fatalf("%d updates, want both flights of itinerary", len(updates))
+	}
+
+	s := NewDiffSummary(additions, removals, updates, nil)
+	if s.Counts.Updates != 2 || s.Counts.Events[PriceIncrease] != 1 {
+		t.Errorf("counts %+v, want 2 updates and 1 price increase", s.Counts)
+	}
+	if s.PriceMovement.Count != 1 || s.PriceMovement.Increases != 1 || s.PriceMovement.Average != 100 {
+		t.Errorf("price movement %+v, want a single increase by 100", s.PriceMovement)
+	}
+	if len(s.BiggestMovers) != 1 || (s.BiggestMovers[0].Key != "AI:996" && s.BiggestMovers[0].Key != "AI:332") {
+		t.Errorf("movers %+v, want one keyed by profile", s.BiggestMovers)
+	}
+}
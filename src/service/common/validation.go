@@ -0,0 +1,230 @@
+package common
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//Input error codes
+const (
+	CodeUnreadableUpload       = "unreadable_upload"
+	CodeEmptyContent           = "empty_content"
+	CodeNotXML                 = "not_xml"
+	CodeMalformedXML           = "malformed_xml"
+	CodeUnexpectedRoot         = "unexpected_root"
+	CodeBadTimestamp           = "bad_timestamp"
+	CodeMissingSource          = "missing_source"
+	CodeMissingDestination     = "missing_destination"
+	CodeArrivalBeforeDeparture = "arrival_before_departure"
+	CodeMissingTotalAmount     = "missing_total_amount"
+)
+
+//MaxInputErrors limits number of errors reported for a single input
+const MaxInputErrors = 100
+
+//InputError is a rejected input with position where possible. Offset is a byte offset, Line and Column
+//are set when the whole content is available
+type InputError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Offset  int64  `json:"offset,omitempty"`
+}
+
+func (e *InputError) Error() string {
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	case e.Offset > 0:
+		return fmt.Sprintf("offset %d: %s", e.Offset, e.Message)
+	}
+	return e.Message
+}
+
+//InputErrors is a list of input errors found in a single input
+type InputErrors []*InputError
+
+func (e InputErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, err := range e {
+		messages[idx] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+//position sets Line and Column of error by Offset in content
+func (e *InputError) position(content []byte) {
+	if e.Offset <= 0 || e.Offset > int64(len(content)) {
+		return
+	}
+	head := content[:e.Offset]
+	e.Line = bytes.Count(head, []byte("\n")) + 1
+	e.Column = len(head) - bytes.LastIndexByte(head, '\n')
+}
+
+//decodeError converts xml decoding error into InputError
+func decodeError(err error, content []byte) error {
+	switch err := err.(type) {
+	case *InputError:
+		err.position(content)
+		return err
+	case *xml.SyntaxError:
+		return &InputError{Code: CodeMalformedXML, Message: err.Msg, Line: err.Line}
+	}
+	if err == io.EOF {
+		return &InputError{Code: CodeNotXML, Message: "no xml element found"}
+	}
+	return &InputError{Code: CodeMalformedXML, Message: err.Error()}
+}
+
+//checkRoot checks content is not empty and its root element is AirFareSearchResponse
+func checkRoot(content []byte) error {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return &InputError{Code: CodeEmptyContent, Message: "empty content"}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		offset := d.InputOffset()
+		token, err := d.Token()
+		if err != nil {
+			return decodeError(err, content)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "AirFareSearchResponse" {
+				e := &InputError{Code: CodeUnexpectedRoot, Message: fmt.Sprintf("unexpected root element %s", start.Name.Local), Offset: offset + 1}
+				e.position(content)
+				return e
+			}
+			return nil
+		}
+	}
+}
+
+//inputIssue is an error of decoded itinerary found before its position is known
+type inputIssue struct {
+	itinerary int
+	leg       string //onward, return or empty for pricing
+	flight    int
+	err       *InputError
+}
+
+//validatePricedFlights checks flights have airports and consistent times and pricing has TotalAmount
+func validatePricedFlights(f *PricedFlights, itinerary int) []inputIssue {
+	var issues []inputIssue
+	legs := []struct {
+		name  string
+		items *PricedItinerary
+	}{{"onward", &f.OnwardPricedItinerary}, {"return", &f.ReturnPricedItinerary}}
+
+	for _, l := range legs {
+		leg, items := l.name, l.items
+		for idx := range items.Flights.Flight {
+			flight := &items.Flights.Flight[idx]
+			add := func(code string, format string, args ...interface{}) {
+				message := fmt.Sprintf("%s flight %s %s: ", leg, flight.Carrier.Name, flight.FlightNumber) + fmt.Sprintf(format, args...)
+				issues = append(issues, inputIssue{itinerary, leg, idx, &InputError{Code: code, Message: message}})
+			}
+
+			if flight.Source == "" {
+				add(CodeMissingSource, "no Source")
+			}
+			if flight.Destination == "" {
+				add(CodeMissingDestination, "no Destination")
+			}
+			switch {
+			case flight.DepartureTimeStamp.IsZero():
+				add(CodeBadTimestamp, "no DepartureTimeStamp")
+			case flight.ArrivalTimeStamp.IsZero():
+				add(CodeBadTimestamp, "no ArrivalTimeStamp")
+			case flight.ArrivalTimeStamp.Before(flight.DepartureTimeStamp.Time):
+				add(CodeArrivalBeforeDeparture, "arrival %s is before departure %s", flight.ArrivalTimeStamp.Format("2006-01-02T1504"), flight.DepartureTimeStamp.Format("2006-01-02T1504"))
+			}
+		}
+	}
+	if _, ok := f.Pricing.GetTotalAmount(); !ok {
+		issues = append(issues, inputIssue{itinerary, "", 0, &InputError{Code: CodeMissingTotalAmount, Message: "pricing has no SingleAdult TotalAmount"}})
+	}
+	return issues
+}
+
+//itineraryOffsets are byte offsets of PricedItineraries Flights element parts
+type itineraryOffsets struct {
+	itinerary int64
+	pricing   int64
+	legs      map[string][]int64
+}
+
+//offset returns offset of leg flight, of pricing if leg is empty, or of the itinerary if part is not found
+func (o *itineraryOffsets) offset(leg string, flight int) int64 {
+	switch {
+	case leg == "" && o.pricing > 0:
+		return o.pricing
+	case leg != "" && flight < len(o.legs[leg]):
+		return o.legs[leg][flight]
+	}
+	return o.itinerary
+}
+
+//locateItineraries finds offsets of itineraries, their flights and pricing in content
+func locateItineraries(content []byte) []itineraryOffsets {
+	var result []itineraryOffsets
+	var path []string
+	d := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		offset := d.InputOffset()
+		token, err := d.Token()
+		if err != nil {
+			return result
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			switch {
+			case len(path) == 3 && path[1] == "PricedItineraries" && t.Name.Local == "Flights":
+				result = append(result, itineraryOffsets{itinerary: offset + 1, legs: make(map[string][]int64)})
+			case len(path) == 4 && path[2] == "Flights" && t.Name.Local == "Pricing" && len(result) > 0:
+				result[len(result)-1].pricing = offset + 1
+			case len(path) == 6 && t.Name.Local == "Flight" && len(result) > 0:
+				leg := map[string]string{"OnwardPricedItinerary": "onward", "ReturnPricedItinerary": "return"}[path[3]]
+				legs := result[len(result)-1].legs
+				legs[leg] = append(legs[leg], offset+1)
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+}
+
+//validateAirFareSearchResponse checks decoded content, found errors are positioned in content
+func validateAirFareSearchResponse(data *AirFareSearchResponse, content []byte) error {
+	var issues []inputIssue
+	for idx := range data.PricedItineraries.Flights {
+		issues = append(issues, validatePricedFlights(&data.PricedItineraries.Flights[idx], idx)...)
+		if len(issues) >= MaxInputErrors {
+			issues = issues[:MaxInputErrors]
+			break
+		}
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	offsets := locateItineraries(content)
+	var errs InputErrors
+	for _, issue := range issues {
+		if issue.itinerary < len(offsets) {
+			issue.err.Offset = offsets[issue.itinerary].offset(issue.leg, issue.flight)
+			issue.err.position(content)
+		}
+		errs = append(errs, issue.err)
+	}
+	return errs
+}
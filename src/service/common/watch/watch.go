@@ -0,0 +1,331 @@
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"service/common"
+	"service/common/criteria"
+	"service/common/store"
+	"sort"
+	"time"
+)
+
+//WatchesCollection is a store collection of watches
+const WatchesCollection = "watches"
+
+//Watch types
+const (
+	//RoutePriceBelow matches when the cheapest Source-Destination route costs less than Threshold
+	RoutePriceBelow = "route_price_below"
+	//FlightChange matches when flight FlightNumber of Carrier is added, removed or modified
+	FlightChange = "flight_change"
+	//CheapestDrop matches when the cheapest Source-Destination route price drops by Percent
+	CheapestDrop = "cheapest_drop"
+)
+
+//Watch is a rule evaluated on every new snapshot
+type Watch struct {
+	ID                string    `json:"id"`
+	Type              string    `json:"type"`
+	Series            string    `json:"series,omitempty"`
+	Source            string    `json:"source,omitempty"`
+	Destination       string    `json:"destination,omitempty"`
+	MaxFlightsInRoute int       `json:"maxFlightsInRoute,omitempty"`
+	Carrier           string    `json:"carrier,omitempty"`
+	FlightNumber      string    `json:"flightNumber,omitempty"`
+	Threshold         float32   `json:"threshold,omitempty"`
+	Currency          string    `json:"currency,omitempty"`
+	Percent           float32   `json:"percent,omitempty"`
+	WebhookURL        string    `json:"webhookUrl,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+//New creates Watch by request and validates it
+func New(req *common.WatchRequest) (*Watch, error) {
+	w := Watch{
+		Type:              req.Type,
+		Series:            req.Series,
+		Source:            req.Source,
+		Destination:       req.Destination,
+		MaxFlightsInRoute: req.MaxFlightsInRoute,
+		Carrier:           req.Carrier,
+		FlightNumber:      req.FlightNumber,
+		Threshold:         req.Threshold,
+		Currency:          req.Currency,
+		Percent:           req.Percent,
+		WebhookURL:        req.WebhookURL,
+		CreatedAt:         time.Now().UTC(),
+	}
+
+	switch w.Type {
+	case RoutePriceBelow:
+		if w.Source == "" || w.Destination == "" {
+			return nil, errors.New("source and destination required")
+		}
+		if w.Threshold <= 0 {
+			return nil, errors.New("threshold must be positive")
+		}
+	case FlightChange:
+		if w.FlightNumber == "" {
+			return nil, errors.New("flight_number required")
+		}
+	case CheapestDrop:
+		if w.Source == "" || w.Destination == "" {
+			return nil, errors.New("source and destination required")
+		}
+		if w.Percent <= 0 || w.Percent > 100 {
+			return nil, errors.New("percent must be in (0, 100]")
+		}
+	default:
+		return nil, fmt.Errorf("unknown watch type %q", w.Type)
+	}
+	if w.WebhookURL != "" {
+		if err := ValidateWebhookURL(w.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	w.ID = hex.EncodeToString(id)
+
+	return &w, nil
+}
+
+//Save writes watch to store
+func (w *Watch) Save(s *store.Store) error {
+	return s.SaveDocument(WatchesCollection, w.ID, w)
+}
+
+//Load reads watch from store
+func Load(s *store.Store, id string) (*Watch, error) {
+	var w Watch
+	if err := s.LoadDocument(WatchesCollection, id, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+//List reads all watches from store ordered by creation time
+func List(s *store.Store) ([]*Watch, error) {
+	ids, err := s.ListDocuments(WatchesCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var watches []*Watch
+	for _, id := range ids {
+		w, err := Load(s, id)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	sort.SliceStable(watches, func(i, j int) bool {
+		return watches[i].CreatedAt.Before(watches[j].CreatedAt)
+	})
+	return watches, nil
+}
+
+//Delete removes watch and its delivery log from store
+func Delete(s *store.Store, id string) error {
+	if err := s.DeleteDocument(WatchesCollection, id); err != nil {
+		return err
+	}
+	if err := s.DeleteDocument(DeliveriesCollection, id); err != nil && err != store.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+//Match is a watch triggered by snapshot
+type Match struct {
+	WatchID     string      `json:"watchId"`
+	Type        string      `json:"type"`
+	Series      string      `json:"series"`
+	DatasetID   string      `json:"datasetId"`
+	RequestTime time.Time   `json:"requestTime"`
+	Message     string      `json:"message"`
+	Details     interface{} `json:"details"`
+}
+
+//ErrSearchTruncated is returned when cheapest route search is cut short by search limits
+var ErrSearchTruncated = errors.New("cheapest route search truncated")
+
+//Evaluate checks watch against current snapshot data and the previous one. Previous is nil for the first snapshot.
+//Match is nil when watch isn't triggered. Route price watch is triggered when the price falls below threshold,
+//not while it stays there
+func (w *Watch) Evaluate(ctx context.Context, previous *common.AirFareSearchResponse, current *common.AirFareSearchResponse) (*Match, error) {
+	switch w.Type {
+	case RoutePriceBelow:
+		route, price, ok, err := w.cheapestRoute(ctx, current)
+		if err != nil || !ok || !w.isBelow(route, price) {
+			return nil, err
+		}
+		if previous != nil {
+			oldRoute, oldPrice, ok, err := w.cheapestRoute(ctx, previous)
+			if err != nil || (ok && w.isBelow(oldRoute, oldPrice)) {
+				return nil, err
+			}
+		}
+		return &Match{
+			Message: fmt.Sprintf("%s-%s route costs %.2f, below %.2f", w.Source, w.Destination, price, w.Threshold),
+			Details: map[string]interface{}{"route": route, "price": price},
+		}, nil
+
+	case FlightChange:
+		if previous == nil {
+			return nil, nil
+		}
+		listA := common.NewFlightsList(previous)
+		listB := common.NewFlightsList(current)
+		options := common.DefaultCompareOptions()
+		additions, removals, updates := listB.Diff(listA, &options)
+
+		additions = w.filterFlights(additions)
+		removals = w.filterFlights(removals)
+		var modifications []common.FlightUpdate
+		for _, u := range updates {
+			if w.isWatchedFlight(u.FlightItem.Flight) {
+				modifications = append(modifications, u)
+			}
+		}
+		if len(additions)+len(removals)+len(modifications) == 0 {
+			return nil, nil
+		}
+		return &Match{
+			Message: fmt.Sprintf("flight %s changed", w.FlightNumber),
+			Details: map[string]interface{}{"additions": additions, "removals": removals, "updates": modifications},
+		}, nil
+
+	case CheapestDrop:
+		if previous == nil {
+			return nil, nil
+		}
+		_, oldPrice, okA, err := w.cheapestRoute(ctx, previous)
+		if err != nil {
+			return nil, err
+		}
+		route, newPrice, okB, err := w.cheapestRoute(ctx, current)
+		if err != nil || !okA || !okB || oldPrice <= 0 {
+			return nil, err
+		}
+		drop := (oldPrice - newPrice) / oldPrice * 100
+		if drop < w.Percent {
+			return nil, nil
+		}
+		return &Match{
+			Message: fmt.Sprintf("%s-%s cheapest price dropped by %.1f%%", w.Source, w.Destination, drop),
+			Details: map[string]interface{}{"route": route, "oldPrice": oldPrice, "newPrice": newPrice, "percent": drop},
+		}, nil
+	}
+	return nil, nil
+}
+
+//cheapestRoute searches the cheapest Source-Destination route of data. Not ok means there is no route, a search
+//cut short by limits or ctx is an error, as the cheapest route may be among unexplored ones
+func (w *Watch) cheapestRoute(ctx context.Context, data *common.AirFareSearchResponse) (common.Route, float32, bool, error) {
+	minCost := criteria.NewMinimumCostCriterion()
+	g := common.NewFlightsGraph(data)
+	stats, err := g.SearchOptimalPathsContext(ctx, w.Source, w.Destination, w.MaxFlightsInRoute, common.DefaultSearchLimits().Budget(), minCost)
+	if err != nil {
+		return common.Route{}, 0, false, err
+	}
+	if stats.Truncated {
+		return common.Route{}, 0, false, fmt.Errorf("%w: %s", ErrSearchTruncated, stats.Reason)
+	}
+
+	paths := minCost.GetResult()
+	if len(paths) == 0 {
+		return common.Route{}, 0, false, nil
+	}
+	return common.NewRoute(paths[0]), minCost.Value.(float32), true, nil
+}
+
+func (w *Watch) isBelow(route common.Route, price float32) bool {
+	return price < w.Threshold && w.matchesCurrency(route)
+}
+
+func (w *Watch) matchesCurrency(route common.Route) bool {
+	if w.Currency == "" {
+		return true
+	}
+	for _, item := range route.Flights {
+		if item.Pricing.Currency != w.Currency {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *Watch) isWatchedFlight(f *common.Flight) bool {
+	if f.FlightNumber != w.FlightNumber {
+		return false
+	}
+	return w.Carrier == "" || f.Carrier.ID == w.Carrier || f.Carrier.Name == w.Carrier
+}
+
+func (w *Watch) filterFlights(items []common.FlightItem) []common.FlightItem {
+	var filtered []common.FlightItem
+	for _, item := range items {
+		if w.isWatchedFlight(item.Flight) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+//EvaluationError is a watch which couldn't be evaluated against snapshot
+type EvaluationError struct {
+	WatchID string `json:"watchId"`
+	Error   string `json:"error"`
+}
+
+//EvaluateAll checks every watch of series against new snapshot and enqueues matches for delivery. Returned
+//deliveries are pending. Watches which couldn't be evaluated, e.g. due truncated search, are reported
+//and skipped. Evaluation is abandoned with ctx error when ctx is done
+func EvaluateAll(ctx context.Context, s *store.Store, n *Notifier, series string, snapshot common.Snapshot, previous *common.AirFareSearchResponse, current *common.AirFareSearchResponse) ([]*Delivery, []EvaluationError, error) {
+	watches, err := List(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var deliveries []*Delivery
+	var failures []EvaluationError
+	for _, w := range watches {
+		if w.Series != "" && w.Series != series {
+			continue
+		}
+		match, err := w.Evaluate(ctx, previous, current)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return deliveries, failures, ctxErr
+		}
+		if err != nil {
+			failures = append(failures, EvaluationError{WatchID: w.ID, Error: err.Error()})
+			continue
+		}
+		if match == nil {
+			continue
+		}
+		match.WatchID = w.ID
+		match.Type = w.Type
+		match.Series = series
+		match.DatasetID = snapshot.DatasetID
+		match.RequestTime = snapshot.RequestTime
+
+		delivery, err := n.Enqueue(s, w, match)
+		if err != nil {
+			return deliveries, failures, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, failures, nil
+}
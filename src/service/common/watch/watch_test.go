@@ -0,0 +1,60 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"service/common"
+	"testing"
+)
+
+//snapshot returns data of a single DXB-BKK flight priced at price
+func snapshot(t *testing.T, price int) *common.AirFareSearchResponse {
+	content := fmt.Sprintf(`<AirFareSearchResponse RequestTime="28-09-2015 20:23:49" ResponseTime="28-09-2015 20:23:56"><RequestId>test</RequestId><PricedItineraries>`+
+		`<Flights><OnwardPricedItinerary><Flights><Flight><Carrier id="AI">AirIndia</Carrier><FlightNumber>996</FlightNumber><Source>DXB</Source><Destination>BKK</Destination>`+
+		`<DepartureTimeStamp>2018-10-22T0005</DepartureTimeStamp><ArrivalTimeStamp>2018-10-22T1005</ArrivalTimeStamp><Class>G</Class><NumberOfStops>0</NumberOfStops><FareBasis>GLOW</FareBasis>`+
+		`<WarningText></WarningText><TicketType>E</TicketType></Flight></Flights></OnwardPricedItinerary>`+
+		`<Pricing currency="SGD"><ServiceCharges type="SingleAdult" ChargeType="TotalAmount">%d</ServiceCharges></Pricing></Flights>`+
+		`</PricedItineraries></AirFareSearchResponse>`, price)
+
+	data, err := common.ParseAirFareSearchResponse([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRoutePriceBelowIsEdgeTriggered(t *testing.T) {
+	w := &Watch{Type: RoutePriceBelow, Source: "DXB", Destination: "BKK", Threshold: 500}
+
+	tests := []struct {
+		name     string
+		previous *common.AirFareSearchResponse
+		current  *common.AirFareSearchResponse
+		fires    bool
+	}{
+		{"first snapshot below", nil, snapshot(t, 400), true},
+		{"first snapshot above", nil, snapshot(t, 600), false},
+		{"drops below", snapshot(t, 600), snapshot(t, 400), true},
+		{"drops to threshold", snapshot(t, 600), snapshot(t, 500), false},
+		{"stays below", snapshot(t, 450), snapshot(t, 400), false},
+		{"rises above", snapshot(t, 400), snapshot(t, 600), false},
+	}
+	for _, test := range tests {
+		match, err := w.Evaluate(context.Background(), test.previous, test.current)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if (match != nil) != test.fires {
+			t.Errorf("%s: match = %+v, want fired %v", test.name, match, test.fires)
+		}
+	}
+}
+
+func TestRoutePriceBelowCurrency(t *testing.T) {
+	w := &Watch{Type: RoutePriceBelow, Source: "DXB", Destination: "BKK", Threshold: 500, Currency: "USD"}
+
+	match, err := w.Evaluate(context.Background(), nil, snapshot(t, 400))
+	if err != nil || match != nil {
+		t.Fatalf("match = %+v, err = %v, want no match of other currency", match, err)
+	}
+}
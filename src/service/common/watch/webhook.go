@@ -0,0 +1,360 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"service/common/store"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//DeliveriesCollection is a store collection of delivery logs, one document per watch
+const DeliveriesCollection = "deliveries"
+
+//MaxLoggedDeliveries number of the latest deliveries kept in watch log
+var MaxLoggedDeliveries = 100
+
+//SignatureHeader carries hex encoded HMAC-SHA256 of request body, omitted when there is no secret
+const SignatureHeader = "X-Signature"
+
+//ErrForbiddenAddress is returned for webhook hosts in loopback, private, link-local, shared or unspecified networks
+var ErrForbiddenAddress = errors.New("webhook: forbidden address")
+
+var sharedNetwork = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || sharedNetwork.Contains(ip)
+}
+
+//ValidateWebhookURL checks that raw is an absolute http(s) url of a public host. Host names are resolved
+//and every address has to be public
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("webhook: host required")
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = net.LookupIP(host); err != nil {
+			return fmt.Errorf("webhook: %v", err)
+		}
+	}
+	for _, ip := range ips {
+		if forbiddenIP(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+//publicClient returns http client which refuses to connect to forbidden addresses, so webhook hosts can't
+//be pointed to internal services after validation or by redirects
+func publicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || forbiddenIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+//Notifier posts matches to webhooks in background. Deliveries are logged as pending before they are sent,
+//so the delivery log is an outbox which is resumed by Start after restart
+type Notifier struct {
+	Client     *http.Client
+	URL        string //used when watch has no own webhook url
+	Secret     string
+	Retries    int
+	RetryDelay time.Duration //doubled after every failed attempt
+	Timeout    time.Duration //limits all attempts of a delivery
+
+	start sync.Once
+	wg    sync.WaitGroup
+}
+
+//NewNotifierFromEnv creates Notifier configured by env WEBHOOK_URL, WEBHOOK_SECRET, WEBHOOK_RETRIES and WEBHOOK_RETRY_DELAY.
+//Requests are unsigned when WEBHOOK_SECRET is empty. Connections to non-public addresses are refused
+func NewNotifierFromEnv() *Notifier {
+	n := Notifier{
+		Client:     publicClient(10 * time.Second),
+		URL:        os.Getenv("WEBHOOK_URL"),
+		Secret:     os.Getenv("WEBHOOK_SECRET"),
+		Retries:    3,
+		RetryDelay: 500 * time.Millisecond,
+		Timeout:    time.Minute,
+	}
+	if retries, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRIES")); err == nil && retries >= 0 {
+		n.Retries = retries
+	}
+	if delay, err := time.ParseDuration(os.Getenv("WEBHOOK_RETRY_DELAY")); err == nil {
+		n.RetryDelay = delay
+	}
+	return &n
+}
+
+//Sign returns hex encoded HMAC-SHA256 of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//Attempt is a single webhook call
+type Attempt struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+//Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+//Delivery is a match sent to webhook with all attempts made
+type Delivery struct {
+	ID       string    `json:"id"`
+	WatchID  string    `json:"watchId"`
+	URL      string    `json:"url"`
+	Match    *Match    `json:"match"`
+	Attempts []Attempt `json:"attempts"`
+	Status   string    `json:"status"`
+}
+
+//Start resumes pending deliveries of watch logs in s. Only the first call has effect
+func (n *Notifier) Start(s *store.Store) error {
+	var err error
+	n.start.Do(func() {
+		var pending []*Delivery
+		if pending, err = Pending(s); err != nil {
+			return
+		}
+		for _, delivery := range pending {
+			n.dispatch(s, delivery)
+		}
+	})
+	return err
+}
+
+//Enqueue logs match of watch as pending delivery and sends it in background. Returned delivery is the
+//pending log entry, results of attempts are written to the delivery log
+func (n *Notifier) Enqueue(s *store.Store, w *Watch, match *Match) (*Delivery, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	delivery := Delivery{
+		ID:      hex.EncodeToString(id),
+		WatchID: w.ID,
+		URL:     w.WebhookURL,
+		Match:   match,
+		Status:  StatusPending,
+	}
+	if delivery.URL == "" {
+		delivery.URL = n.URL
+	}
+	if delivery.URL == "" {
+		delivery.Attempts = append(delivery.Attempts, Attempt{Time: time.Now().UTC(), Error: "no webhook url configured"})
+		delivery.Status = StatusFailed
+	}
+
+	if err := LogDelivery(s, &delivery); err != nil {
+		return nil, err
+	}
+	if delivery.Status == StatusPending {
+		pending := delivery
+		n.dispatch(s, &pending)
+	}
+	return &delivery, nil
+}
+
+//dispatch delivers in a goroutine with its own deadline, so it outlives the request which enqueued it
+func (n *Notifier) dispatch(s *store.Store, delivery *Delivery) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		ctx := context.Background()
+		if n.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, n.Timeout)
+			defer cancel()
+		}
+
+		n.Deliver(ctx, delivery)
+		if err := UpdateDelivery(s, delivery); err != nil {
+			log.Printf("watch %s: delivery %s: %v", delivery.WatchID, delivery.ID, err)
+		}
+	}()
+}
+
+//Wait blocks until dispatched deliveries are finished
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+//Deliver posts signed match json to delivery url. Non 2xx responses and network errors are retried until
+//attempts are exhausted or ctx is done
+func (n *Notifier) Deliver(ctx context.Context, delivery *Delivery) {
+	delivery.Status = StatusFailed
+
+	body, err := json.Marshal(delivery.Match)
+	if err != nil {
+		delivery.Attempts = append(delivery.Attempts, Attempt{Time: time.Now().UTC(), Error: err.Error()})
+		return
+	}
+
+	delay := n.RetryDelay
+	for attempt := 0; attempt <= n.Retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				delivery.Attempts = append(delivery.Attempts, Attempt{Time: time.Now().UTC(), Error: ctx.Err().Error()})
+				return
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+
+		result := n.post(ctx, delivery.URL, body)
+		delivery.Attempts = append(delivery.Attempts, result)
+		if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+			delivery.Status = StatusDelivered
+			return
+		}
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) Attempt {
+	attempt := Attempt{Time: time.Now().UTC()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.Secret, body))
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+var deliveriesMu sync.Mutex
+
+//LogDelivery appends delivery to watch delivery log
+func LogDelivery(s *store.Store, delivery *Delivery) error {
+	deliveriesMu.Lock()
+	defer deliveriesMu.Unlock()
+
+	deliveries, err := LoadDeliveries(s, delivery.WatchID)
+	if err != nil {
+		return err
+	}
+	deliveries = append(deliveries, delivery)
+	if len(deliveries) > MaxLoggedDeliveries {
+		deliveries = deliveries[len(deliveries)-MaxLoggedDeliveries:]
+	}
+	return s.SaveDocument(DeliveriesCollection, delivery.WatchID, deliveries)
+}
+
+//UpdateDelivery replaces delivery log entry by delivery id. Entries which are no longer logged are skipped
+func UpdateDelivery(s *store.Store, delivery *Delivery) error {
+	deliveriesMu.Lock()
+	defer deliveriesMu.Unlock()
+
+	deliveries, err := LoadDeliveries(s, delivery.WatchID)
+	if err != nil {
+		return err
+	}
+	for idx := range deliveries {
+		if deliveries[idx].ID == delivery.ID {
+			deliveries[idx] = delivery
+			return s.SaveDocument(DeliveriesCollection, delivery.WatchID, deliveries)
+		}
+	}
+	return nil
+}
+
+//LoadDeliveries reads watch delivery log
+func LoadDeliveries(s *store.Store, watchID string) ([]*Delivery, error) {
+	var deliveries []*Delivery
+	err := s.LoadDocument(DeliveriesCollection, watchID, &deliveries)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	return deliveries, err
+}
+
+//Pending returns pending deliveries of all watch logs
+func Pending(s *store.Store) ([]*Delivery, error) {
+	ids, err := s.ListDocuments(DeliveriesCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*Delivery
+	for _, id := range ids {
+		deliveries, err := LoadDeliveries(s, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, delivery := range deliveries {
+			if delivery.Status == StatusPending {
+				pending = append(pending, delivery)
+			}
+		}
+	}
+	return pending, nil
+}
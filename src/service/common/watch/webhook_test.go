@@ -0,0 +1,200 @@
+package watch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"service/common/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+//recorder is a webhook stand-in which fails the first failures requests with 503
+type recorder struct {
+	mu         sync.Mutex
+	failures   int
+	times      []time.Time
+	signatures []string
+	bodies     [][]byte
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times = append(r.times, time.Now())
+	r.signatures = append(r.signatures, req.Header.Get(SignatureHeader))
+	r.bodies = append(r.bodies, body)
+	if len(r.times) <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func testNotifier(server *httptest.Server, secret string) *Notifier {
+	return &Notifier{
+		Client:     server.Client(),
+		Secret:     secret,
+		Retries:    3,
+		RetryDelay: 20 * time.Millisecond,
+		Timeout:    5 * time.Second,
+	}
+}
+
+func testStore(t *testing.T) *store.Store {
+	s, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestEnqueueRetriesWithBackoff(t *testing.T) {
+	rec := &recorder{failures: 2}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	s := testStore(t)
+	n := testNotifier(server, "secret")
+	w := &Watch{ID: "w1", WebhookURL: server.URL}
+
+	pending, err := n.Enqueue(s, w, &Match{WatchID: w.ID, Message: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != StatusPending || len(pending.Attempts) != 0 {
+		t.Fatalf("enqueued delivery = %+v, want pending without attempts", pending)
+	}
+	n.Wait()
+
+	if len(rec.times) != 3 {
+		t.Fatalf("webhook called %d times, want 3", len(rec.times))
+	}
+	for idx := 1; idx < len(rec.times); idx++ {
+		want := n.RetryDelay << uint(idx-1)
+		if gap := rec.times[idx].Sub(rec.times[idx-1]); gap < want {
+			t.Errorf("attempt %d after %v, want at least %v", idx+1, gap, want)
+		}
+	}
+	for idx, signature := range rec.signatures {
+		if want := "sha256=" + Sign("secret", rec.bodies[idx]); signature != want {
+			t.Errorf("attempt %d signature = %q, want %q", idx+1, signature, want)
+		}
+	}
+
+	deliveries, err := LoadDeliveries(s, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("logged %d deliveries, want 1", len(deliveries))
+	}
+	logged := deliveries[0]
+	if logged.ID != pending.ID || logged.Status != StatusDelivered {
+		t.Errorf("logged delivery %s status %q, want %s %q", logged.ID, logged.Status, pending.ID, StatusDelivered)
+	}
+	var codes []int
+	for _, attempt := range logged.Attempts {
+		codes = append(codes, attempt.StatusCode)
+	}
+	if len(codes) != 3 || codes[0] != http.StatusServiceUnavailable || codes[1] != http.StatusServiceUnavailable || codes[2] != http.StatusNoContent {
+		t.Errorf("logged attempt statuses = %v, want [503 503 204]", codes)
+	}
+}
+
+func TestEnqueueFailsWhenRetriesExhausted(t *testing.T) {
+	rec := &recorder{failures: 10}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	s := testStore(t)
+	n := testNotifier(server, "")
+	n.Retries = 1
+	w := &Watch{ID: "w1", WebhookURL: server.URL}
+
+	if _, err := n.Enqueue(s, w, &Match{WatchID: w.ID}); err != nil {
+		t.Fatal(err)
+	}
+	n.Wait()
+
+	for idx, signature := range rec.signatures {
+		if signature != "" {
+			t.Errorf("attempt %d signed without secret: %q", idx+1, signature)
+		}
+	}
+	deliveries, err := LoadDeliveries(s, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != StatusFailed || len(deliveries[0].Attempts) != 2 {
+		t.Fatalf("logged deliveries = %+v, want one failed delivery of 2 attempts", deliveries)
+	}
+}
+
+func TestStartResumesPendingDeliveries(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	s := testStore(t)
+	pending := &Delivery{ID: "d1", WatchID: "w1", URL: server.URL, Match: &Match{WatchID: "w1"}, Status: StatusPending}
+	if err := LogDelivery(s, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	n := testNotifier(server, "secret")
+	if err := n.Start(s); err != nil {
+		t.Fatal(err)
+	}
+	n.Wait()
+
+	deliveries, err := LoadDeliveries(s, "w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.times) != 1 || len(deliveries) != 1 || deliveries[0].Status != StatusDelivered {
+		t.Fatalf("webhook called %d times, logged %+v, want one delivered", len(rec.times), deliveries)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"ftp://93.184.216.34/hook", false},
+		{"file:///etc/passwd", false},
+		{"/relative", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://localhost:3000/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://172.16.5.4/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://100.64.0.1/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, test := range tests {
+		if err := ValidateWebhookURL(test.url); (err == nil) != test.ok {
+			t.Errorf("ValidateWebhookURL(%q) = %v, want ok %v", test.url, err, test.ok)
+		}
+	}
+}
+
+func TestPublicClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(&recorder{})
+	defer server.Close()
+
+	if _, err := publicClient(time.Second).Get(server.URL); err == nil {
+		t.Fatal("request to loopback server succeeded")
+	}
+}
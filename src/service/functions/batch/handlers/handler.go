@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/criteria"
+	"service/common/graph"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//WorkersCount number of queries executed concurrently
+var WorkersCount int = 10
+
+//Handle api call handler. Consumes multipart/form-data, produces json
+func Handle(c *gin.Context) {
+	var req common.BatchDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	queries, err := parseQueries(req.Queries)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "queries", err)
+		return
+	}
+
+	start := time.Now()
+	data, err := common.LoadData(req.Data, req.DatasetID)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+	api.Dataset(c, "data", req.DatasetID, data)
+	api.Timing(c, "parse", start)
+
+	start = time.Now()
+	g := common.NewFlightsGraph(data)
+	api.Timing(c, "graph", start)
+
+	start = time.Now()
+	budget := req.Budget()
+
+	type queryResult struct {
+		id     string
+		result map[string]interface{}
+		err    error
+	}
+
+	jobs := make(chan common.BatchQuery)
+	results := make(chan queryResult)
+
+	wgWorkers := sync.WaitGroup{}
+	wgWorkers.Add(WorkersCount)
+
+	for w := 0; w < WorkersCount; w++ {
+		go func() {
+			defer wgWorkers.Done()
+			for query := range jobs {
+				result, err := run(c.Request.Context(), g, query, budget)
+				results <- queryResult{query.ID, result, err}
+			}
+		}()
+	}
+
+	go func() {
+		for _, query := range queries {
+			jobs <- query
+		}
+		close(jobs)
+		wgWorkers.Wait()
+		close(results)
+	}()
+
+	response := make(map[string]interface{})
+	var searchErr error
+	for r := range results {
+		response[r.id] = r.result
+		if r.err != nil && searchErr == nil {
+			searchErr = r.err
+		}
+	}
+	if searchErr != nil {
+		api.SearchError(c, searchErr)
+		return
+	}
+	api.Timing(c, "search", start)
+
+	api.Respond(c, http.StatusOK, gin.H{"results": response})
+}
+
+func parseQueries(raw string) ([]common.BatchQuery, error) {
+	var queries []common.BatchQuery
+	if err := json.Unmarshal([]byte(raw), &queries); err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, errors.New("queries: at least one query required")
+	}
+
+	ids := make(map[string]bool)
+	for idx, query := range queries {
+		if query.ID == "" {
+			return nil, fmt.Errorf("queries[%d]: id required", idx)
+		}
+		if ids[query.ID] {
+			return nil, fmt.Errorf("queries[%d]: duplicate id %q", idx, query.ID)
+		}
+		ids[query.ID] = true
+
+		if query.Type != "list" && query.Type != "rank" {
+			return nil, fmt.Errorf("queries[%d]: type must be list or rank", idx)
+		}
+		if query.Source == "" || query.Destination == "" {
+			return nil, fmt.Errorf("queries[%d]: source and destination required", idx)
+		}
+	}
+	return queries, nil
+}
+
+//run executes query against shared graph within budget. Graph and budget are only read, so queries may run concurrently.
+//Queries are skipped once ctx is done
+func run(ctx context.Context, g *graph.Graph, query common.BatchQuery, budget *graph.Budget) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+
+	switch query.Type {
+	case "list":
+		var routes []common.Route
+		paths, stats, err := g.GetPathsContext(ctx, query.Source, query.Destination, query.MaxFlightsInRoute, budget)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range paths {
+			routes = append(routes, common.NewRoute(&paths[idx]))
+		}
+		result["routes"] = routes
+		result["truncated"], result["reason"] = stats.Truncated, stats.Reason
+
+	case "rank":
+		items := criteria.NewDefaultSet()
+		stats, err := g.SearchOptimalPathsContext(ctx, query.Source, query.Destination, query.MaxFlightsInRoute, budget, items.List()...)
+		if err != nil {
+			return nil, err
+		}
+		result["truncated"], result["reason"] = stats.Truncated, stats.Reason
+
+		for key, criterion := range items {
+			var routes []common.Route
+			for _, p := range criterion.GetResult() {
+				routes = append(routes, common.NewRoute(p))
+			}
+			result[key] = routes
+		}
+	}
+	return result, nil
+}
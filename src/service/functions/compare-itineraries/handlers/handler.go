@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"service/common"
+	"service/common/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Handle api call handler. Consumes multipart/form-data, produces json
+func Handle(c *gin.Context) {
+	var req common.CompareItinerariesDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	severity, err := common.ParseSeverity(req.MinSeverity)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "min_severity", err)
+		return
+	}
+
+	policy, err := common.ParseDuplicatePolicy(req.Duplicates)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "duplicates", err)
+		return
+	}
+
+	start := time.Now()
+	dataA, err := common.LoadData(req.DataA, req.DatasetA)
+	if err != nil {
+		api.LoadError(c, "a", err)
+		return
+	}
+	api.Dataset(c, "a", req.DatasetA, dataA)
+
+	dataB, err := common.LoadData(req.DataB, req.DatasetB)
+	if err != nil {
+		api.LoadError(c, "b", err)
+		return
+	}
+	api.Dataset(c, "b", req.DatasetB, dataB)
+	api.Timing(c, "parse", start)
+
+	start = time.Now()
+	bundlesA, collisionsA, errA := common.NewBundlesWithPolicy(dataA, policy)
+	bundlesB, collisionsB, errB := common.NewBundlesWithPolicy(dataB, policy)
+	collisions := gin.H{"a": collisionsA, "b": collisionsB}
+	if err := errA; err != nil || errB != nil {
+		if err == nil {
+			err = errB
+		}
+		api.Fail(c, http.StatusConflict, api.CodeConflict, "duplicates", err, gin.H{"collisions": collisions})
+		return
+	}
+
+	additions, removals, updates, substitutions := common.DiffBundles(bundlesA, bundlesB, req.Options())
+	api.Timing(c, "diff", start)
+
+	api.Respond(c, http.StatusOK, gin.H{
+		"collisions":    collisions,
+		"additions":     additions,
+		"removals":      removals,
+		"updates":       common.FilterBundleUpdates(updates, severity),
+		"substitutions": substitutions,
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/compare-itineraries/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.Default()
+	router.POST("/compare/itineraries", handlers.Handle)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/compare/itineraries", handlers.Handle)
+
+	server.Start(router)
+}
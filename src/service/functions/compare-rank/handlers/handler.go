@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/criteria"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Handle api call handler. Consumes multipart/form-data, produces json
+func Handle(c *gin.Context) {
+	var req common.CompareRankDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	start := time.Now()
+	dataA, err := common.LoadData(req.DataA, req.DatasetA)
+	if err != nil {
+		api.LoadError(c, "a", err)
+		return
+	}
+	api.Dataset(c, "a", req.DatasetA, dataA)
+
+	dataB, err := common.LoadData(req.DataB, req.DatasetB)
+	if err != nil {
+		api.LoadError(c, "b", err)
+		return
+	}
+	api.Dataset(c, "b", req.DatasetB, dataB)
+	api.Timing(c, "parse", start)
+
+	start = time.Now()
+	graphA := common.NewFlightsGraph(dataA)
+	graphB := common.NewFlightsGraph(dataB)
+	api.Timing(c, "graph", start)
+
+	start = time.Now()
+	budget := req.Budget()
+
+	itemsA := criteria.NewDefaultSet()
+	stats, err := graphA.SearchOptimalPathsContext(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, budget, itemsA.List()...)
+	if err != nil {
+		api.SearchError(c, err)
+		return
+	}
+
+	itemsB := criteria.NewDefaultSet()
+	statsB, err := graphB.SearchOptimalPathsContext(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, budget, itemsB.List()...)
+	if err != nil {
+		api.SearchError(c, err)
+		return
+	}
+	stats.Add(statsB)
+	api.Timing(c, "search", start)
+
+	api.Respond(c, http.StatusOK, gin.H{
+		"criteria":  criteria.Compare(itemsA, itemsB, common.NewFlightsList(dataB)),
+		"truncated": stats.Truncated,
+		"reason":    stats.Reason,
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/compare-rank/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.Default()
+	router.POST("/compare/rank", handlers.Handle)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/compare/rank", handlers.Handle)
+
+	server.Start(router)
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
-	"encoding/xml"
-	"io/ioutil"
+	"bytes"
 	"net/http"
+	"time"
 
 	"service/common"
+	"service/common/api"
+	"service/common/report"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,33 +16,63 @@ func Handle(c *gin.Context) {
 	var req common.CompareRoutesDataRequest
 
 	if err := c.ShouldBind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		api.BindError(c, err, &req)
 		return
 	}
 
-	file, _ := req.DataA.Open()
-	content, _ := ioutil.ReadAll(file)
-
-	var dataA common.AirFareSearchResponse
-	if err := xml.Unmarshal(content, &dataA); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	severity, err := common.ParseSeverity(req.MinSeverity)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "min_severity", err)
 		return
 	}
 
-	file, _ = req.DataB.Open()
-	content, _ = ioutil.ReadAll(file)
+	format, err := report.ParseFormat(req.Format)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "format", err)
+		return
+	}
 
-	var dataB common.AirFareSearchResponse
-	if err := xml.Unmarshal(content, &dataB); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	policy, err := common.ParseDuplicatePolicy(req.Duplicates)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "duplicates", err)
 		return
 	}
 
-	graphA := common.NewFlightsGraph(&dataA)
-	graphB := common.NewFlightsGraph(&dataB)
+	start := time.Now()
+	dataA, err := common.LoadData(req.DataA, req.DatasetA)
+	if err != nil {
+		api.LoadError(c, "a", err)
+		return
+	}
+	api.Dataset(c, "a", req.DatasetA, dataA)
 
-	pathsA := graphA.GetPaths(req.Source, req.Destination, req.MaxFlightsInRoute)
-	pathsB := graphB.GetPaths(req.Source, req.Destination, req.MaxFlightsInRoute)
+	dataB, err := common.LoadData(req.DataB, req.DatasetB)
+	if err != nil {
+		api.LoadError(c, "b", err)
+		return
+	}
+	api.Dataset(c, "b", req.DatasetB, dataB)
+	api.Timing(c, "parse", start)
+
+	start = time.Now()
+	graphA := common.NewFlightsGraph(dataA)
+	graphB := common.NewFlightsGraph(dataB)
+	api.Timing(c, "graph", start)
+
+	start = time.Now()
+	budget := req.Budget()
+	pathsA, stats, err := graphA.GetPathsContext(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, budget)
+	if err != nil {
+		api.SearchError(c, err)
+		return
+	}
+	pathsB, statsB, err := graphB.GetPathsContext(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, budget)
+	if err != nil {
+		api.SearchError(c, err)
+		return
+	}
+	stats.Add(statsB)
+	api.Timing(c, "search", start)
 
 	var routesA []common.Route
 	var routesB []common.Route
@@ -63,14 +95,48 @@ func Handle(c *gin.Context) {
 		routesB = append(routesB, common.Route{Flights: flights})
 	}
 
-	listA := common.NewRoutesList(routesA)
-	listB := common.NewRoutesList(routesB)
+	start = time.Now()
+	listA, errA := common.NewRoutesListWithPolicy(routesA, policy)
+	listB, errB := common.NewRoutesListWithPolicy(routesB, policy)
+	collisions := gin.H{"a": listA.Collisions(), "b": listB.Collisions()}
+	if err := errA; err != nil || errB != nil {
+		if err == nil {
+			err = errB
+		}
+		api.Fail(c, http.StatusConflict, api.CodeConflict, "duplicates", err, gin.H{"collisions": collisions})
+		return
+	}
+
+	additions, removals, updates := listB.Diff(listA, req.Options())
+	updates = common.FilterRouteUpdates(updates, severity)
 
-	response := gin.H{"success": true}
+	var changes []common.ScheduleChange
+	if req.ScheduleWindow > 0 {
+		additions, removals, _ := common.NewFlightsList(dataB).Diff(common.NewFlightsList(dataA), nil)
+		changes, _, _ = common.ReconcileSchedule(additions, removals, time.Duration(req.ScheduleWindow)*time.Minute)
+		common.CheckConnections(changes, routesA)
+	}
+	api.Timing(c, "diff", start)
 
-	response["additions"],
-		response["removals"],
-		response["updates"] = listB.Diff(listA)
+	if format != "json" {
+		var buf bytes.Buffer
+		if err := report.NewRoutesReport(additions, removals, updates, changes).Write(&buf, format); err != nil {
+			api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+			return
+		}
+		api.Report(c, report.ContentTypes[format], buf.Bytes(), len(listA.Collisions()), len(listB.Collisions()), &stats)
+		return
+	}
+
+	response := gin.H{"collisions": collisions, "truncated": stats.Truncated, "reason": stats.Reason}
+
+	response["additions"] = additions
+	response["removals"] = removals
+	response["updates"] = updates
+
+	if req.ScheduleWindow > 0 {
+		response["scheduleChanges"] = changes
+	}
 
-	c.JSON(http.StatusOK, response)
+	api.Respond(c, http.StatusOK, response)
 }
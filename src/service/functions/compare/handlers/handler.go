@@ -1,11 +1,16 @@
 package handlers
 
 import (
-	"encoding/xml"
-	"io/ioutil"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"service/common"
+	"service/common/api"
+	"service/common/report"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,36 +19,152 @@ func Handle(c *gin.Context) {
 	var req common.CompareDataRequest
 
 	if err := c.ShouldBind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		api.BindError(c, err, &req)
 		return
 	}
 
-	file, _ := req.DataA.Open()
-	content, _ := ioutil.ReadAll(file)
+	severity, err := common.ParseSeverity(req.MinSeverity)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "min_severity", err)
+		return
+	}
+
+	format, err := report.ParseFormat(req.Format)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "format", err)
+		return
+	}
+
+	policy, err := common.ParseDuplicatePolicy(req.Duplicates)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "duplicates", err)
+		return
+	}
+
+	profile, err := common.ParseKeyProfile(req.MatchKey, req.MatchFields)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "match_key", err)
+		return
+	}
 
-	var dataA common.AirFareSearchResponse
-	if err := xml.Unmarshal(content, &dataA); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	if req.Stream {
+		if format != "json" || req.Summary || req.ScheduleWindow > 0 {
+			api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "stream", errors.New("stream mode supports neither format, summary nor schedule_window"))
+			return
+		}
+		stream(c, &req, profile, policy, severity)
 		return
 	}
 
-	file, _ = req.DataB.Open()
-	content, _ = ioutil.ReadAll(file)
+	start := time.Now()
+	dataA, err := common.LoadData(req.DataA, req.DatasetA)
+	if err != nil {
+		api.LoadError(c, "a", err)
+		return
+	}
+	api.Dataset(c, "a", req.DatasetA, dataA)
+
+	dataB, err := common.LoadData(req.DataB, req.DatasetB)
+	if err != nil {
+		api.LoadError(c, "b", err)
+		return
+	}
+	api.Dataset(c, "b", req.DatasetB, dataB)
+	api.Timing(c, "parse", start)
 
-	var dataB common.AirFareSearchResponse
-	if err := xml.Unmarshal(content, &dataB); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	start = time.Now()
+	flightsA, errA := common.NewFlightsListWithPolicy(dataA, profile, policy)
+	flightsB, errB := common.NewFlightsListWithPolicy(dataB, profile, policy)
+	collisions := gin.H{"a": flightsA.Collisions(), "b": flightsB.Collisions()}
+	if err := errA; err != nil || errB != nil {
+		if err == nil {
+			err = errB
+		}
+		api.Fail(c, http.StatusConflict, api.CodeConflict, "duplicates", err, gin.H{"collisions": collisions})
 		return
 	}
 
-	flightsA := common.NewFlightsList(&dataA)
-	flightsB := common.NewFlightsList(&dataB)
+	response := gin.H{"matchKey": profile, "collisions": collisions}
 
-	response := gin.H{"success": true}
+	additions, removals, updates := flightsB.Diff(flightsA, req.Options())
 
-	response["additions"],
-		response["removals"],
-		response["updates"] = flightsB.Diff(flightsA)
+	var changes []common.ScheduleChange
+	if req.ScheduleWindow > 0 {
+		changes, additions, removals = common.ReconcileSchedule(additions, removals, time.Duration(req.ScheduleWindow)*time.Minute)
+		response["scheduleChanges"] = changes
+	}
 
-	c.JSON(http.StatusOK, response)
+	updates = common.FilterFlightUpdates(updates, severity)
+	api.Timing(c, "diff", start)
+
+	if format != "json" {
+		var buf bytes.Buffer
+		if err := report.NewFlightsReport(additions, removals, updates, changes).Write(&buf, format); err != nil {
+			api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+			return
+		}
+		api.Report(c, report.ContentTypes[format], buf.Bytes(), len(flightsA.Collisions()), len(flightsB.Collisions()), nil)
+		return
+	}
+
+	if req.Summary {
+		response["summary"] = common.NewDiffSummary(additions, removals, updates, changes)
+	}
+
+	response["additions"] = additions
+	response["removals"] = removals
+	response["updates"] = updates
+
+	api.Respond(c, http.StatusOK, response)
+}
+
+//stream compares snapshots with bounded memory and writes results as ndjson, one StreamRecord per line
+func stream(c *gin.Context, req *common.CompareDataRequest, profile *common.KeyProfile, policy string, severity string) {
+	readerA, err := common.OpenData(req.DataA, req.DatasetA)
+	if err != nil {
+		api.LoadError(c, "a", err)
+		return
+	}
+	defer readerA.Close()
+
+	readerB, err := common.OpenData(req.DataB, req.DatasetB)
+	if err != nil {
+		api.LoadError(c, "b", err)
+		return
+	}
+	defer readerB.Close()
+
+	sc, err := common.NewStreamCompare(profile, policy)
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+	defer sc.Close()
+
+	if err := sc.Partition("a", readerA); err != nil {
+		api.LoadError(c, "a", err)
+		return
+	}
+	if err := sc.Partition("b", readerB); err != nil {
+		api.LoadError(c, "b", err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	var writeErr error
+	err = sc.Run(c.Request.Context(), req.Options(), severity, func(record *common.StreamRecord) error {
+		if writeErr = encoder.Encode(record); writeErr != nil {
+			return writeErr
+		}
+		c.Writer.Flush()
+		return nil
+	})
+
+	if err != nil && err != context.Canceled && err != writeErr {
+		encoder.Encode(&common.StreamRecord{Type: "error", Error: err.Error()})
+		c.Writer.Flush()
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"service/common/api"
 	"service/common/server"
 	"service/functions/compare/handlers"
 
@@ -11,5 +12,8 @@ func main() {
 	router := gin.New()
 	router.POST("/compare", handlers.Handle)
 
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/compare", handlers.Handle)
+
 	server.Start(router)
 }
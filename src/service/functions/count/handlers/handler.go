@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Count modes
+const (
+	ModeExact    = "exact"
+	ModeEstimate = "estimate"
+)
+
+//RouteCount is an exact number of routes of a single length
+type RouteCount struct {
+	Flights int    `json:"flights"`
+	Routes  uint64 `json:"routes"`
+}
+
+//RouteEstimate is an estimated number of routes of a single length
+type RouteEstimate struct {
+	Flights int     `json:"flights"`
+	Routes  float64 `json:"routes"`
+}
+
+//Handle api call handler. Consumes multipart/form-data, produces json
+func Handle(c *gin.Context) {
+	var req common.CountDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = ModeExact
+	}
+	if mode != ModeExact && mode != ModeEstimate {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "mode", fmt.Errorf("unknown count mode %q", req.Mode))
+		return
+	}
+
+	start := time.Now()
+	data, err := common.LoadData(req.Data, req.DatasetID)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+	api.Dataset(c, "data", req.DatasetID, data)
+	api.Timing(c, "parse", start)
+
+	start = time.Now()
+	g := common.NewFlightsGraph(data)
+	api.Timing(c, "graph", start)
+
+	response := gin.H{"mode": mode, "truncated": false, "reason": ""}
+
+	start = time.Now()
+	switch mode {
+	case ModeExact:
+		exact, stats, err := g.CountPaths(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, req.Budget())
+		if err != nil {
+			api.SearchError(c, err)
+			return
+		}
+		var counts []RouteCount
+		var total uint64
+		for idx, n := range exact {
+			counts = append(counts, RouteCount{idx + 1, n})
+			if total+n < total {
+				total = math.MaxUint64
+			} else {
+				total += n
+			}
+		}
+		response["counts"], response["total"] = counts, total
+		response["truncated"], response["reason"] = stats.Truncated, stats.Reason
+		response["overflow"] = total == math.MaxUint64
+
+	case ModeEstimate:
+		estimates, stats, err := g.EstimatePaths(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, req.Budget())
+		if err != nil {
+			api.SearchError(c, err)
+			return
+		}
+		var counts []RouteEstimate
+		var total float64
+		for idx, n := range estimates {
+			counts = append(counts, RouteEstimate{idx + 1, n})
+			total = math.Min(total+n, math.MaxFloat64)
+		}
+		response["counts"], response["total"] = counts, total
+		response["truncated"], response["reason"] = stats.Truncated, stats.Reason
+		response["overflow"] = total == math.MaxFloat64
+	}
+	api.Timing(c, "search", start)
+
+	api.Respond(c, http.StatusOK, response)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/count/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.New()
+	router.POST("/count", handlers.Handle)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/count", handlers.Handle)
+
+	server.Start(router)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Create api call handler. Consumes multipart/form-data, stores xml and produces its id
+func Create(c *gin.Context) {
+	var req common.DatasetDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	content, err := common.ReadUpload(req.Data)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+
+	data, err := common.ParseAirFareSearchResponse(content)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	id, err := s.Put(content)
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	api.Dataset(c, "data", id, data)
+	api.Respond(c, http.StatusCreated, gin.H{"id": id, "dataset": common.NewDatasetInfo(id, content, data)})
+}
+
+//Get api call handler. Produces stored dataset info
+func Get(c *gin.Context) {
+	id := c.Param("id")
+
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	content, err := s.Get(id)
+	if err != nil {
+		api.StoreError(c, "id", err)
+		return
+	}
+
+	data, err := common.ParseAirFareSearchResponse(content)
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	api.Dataset(c, "data", id, data)
+	api.Respond(c, http.StatusOK, gin.H{"dataset": common.NewDatasetInfo(id, content, data)})
+}
+
+//Delete api call handler. Removes stored dataset, datasets which are snapshots of series are kept with 409
+func Delete(c *gin.Context) {
+	id := c.Param("id")
+
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	err = common.DeleteDataset(s, id)
+	if inUse, ok := err.(*common.DatasetInUseError); ok {
+		api.Fail(c, http.StatusConflict, api.CodeConflict, "id", err, gin.H{"series": inUse.Series})
+		return
+	}
+	if err != nil {
+		api.StoreError(c, "id", err)
+		return
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"id": id})
+}
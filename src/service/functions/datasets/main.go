@@ -0,0 +1,23 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/datasets/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.Default()
+	router.POST("/datasets", handlers.Create)
+	router.GET("/datasets/:id", handlers.Get)
+	router.DELETE("/datasets/:id", handlers.Delete)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/datasets", handlers.Create)
+	v2.GET("/datasets/:id", handlers.Get)
+	v2.DELETE("/datasets/:id", handlers.Delete)
+
+	server.Start(router)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Handle api call handler. Produces price history of every flight in series
+func Handle(c *gin.Context) {
+	var req common.HistoryRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	series, err := common.LoadSeries(s, req.Series)
+	if err != nil {
+		api.StoreError(c, "series", err)
+		return
+	}
+	if len(series.Snapshots) == 0 {
+		api.StoreError(c, "series", store.ErrNotFound)
+		return
+	}
+
+	var times []time.Time
+	var snapshots []*common.AirFareSearchResponse
+
+	for _, snapshot := range series.Snapshots {
+		data, err := common.LoadDataset(snapshot.DatasetID)
+		if err != nil {
+			api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+			return
+		}
+		times = append(times, snapshot.RequestTime)
+		snapshots = append(snapshots, data)
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"series": series, "flights": common.NewHistory(times, snapshots)})
+}
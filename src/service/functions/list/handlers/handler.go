@@ -1,35 +1,55 @@
 package handlers
 
 import (
-	"io/ioutil"
+	"context"
 	"net/http"
 	"service/common"
-
-	"encoding/xml"
+	"service/common/api"
+	"service/common/graph"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 //Handle api call handler. Consumes multipart/form-data, produces json
 func Handle(c *gin.Context) {
-	var req common.SingleDataRequest
+	var req common.ListDataRequest
 
 	if err := c.ShouldBind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		api.BindError(c, err, &req)
+		return
+	}
+
+	format, err := common.ParseRouteStream(req.Stream)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "stream", err)
 		return
 	}
 
-	file, _ := req.Data.Open()
-	content, _ := ioutil.ReadAll(file)
+	start := time.Now()
+	data, err := common.LoadData(req.Data, req.DatasetID)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+	api.Dataset(c, "data", req.DatasetID, data)
+	api.Timing(c, "parse", start)
 
-	var data common.AirFareSearchResponse
-	if err := xml.Unmarshal(content, &data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	start = time.Now()
+	g := common.NewFlightsGraph(data)
+	api.Timing(c, "graph", start)
+	if format != "" {
+		stream(c, g, &req, format)
 		return
 	}
 
-	g := common.NewFlightsGraph(&data)
-	paths := g.GetPaths(req.Source, req.Destination, req.MaxFlightsInRoute)
+	start = time.Now()
+	paths, stats, err := g.GetPathsContext(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, req.Budget())
+	if err != nil {
+		api.SearchError(c, err)
+		return
+	}
+	api.Timing(c, "search", start)
 
 	var routes []common.Route
 
@@ -42,7 +62,7 @@ func Handle(c *gin.Context) {
 		routes = append(routes, common.Route{Flights: flights})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "routes": routes})
+	api.Respond(c, http.StatusOK, gin.H{"routes": routes, "truncated": stats.Truncated, "reason": stats.Reason})
 
 	/*fmt.Printf("Got %d paths", len(paths))
 	fmt.Println()
@@ -61,3 +81,31 @@ func Handle(c *gin.Context) {
 		}
 	}*/
 }
+
+//stream writes every route as soon as it is found, followed by summary with search stats. Routes aren't
+//collected, so memory doesn't grow with number of routes
+func stream(c *gin.Context, g *graph.Graph, req *common.ListDataRequest, format string) {
+	c.Header("Content-Type", common.RouteStreamContentTypes[format])
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	writer := common.NewRouteWriter(c.Writer, format)
+	stats, err := g.WalkPaths(c.Request.Context(), req.Source, req.Destination, req.MaxFlightsInRoute, req.Budget(), func(path *graph.Path) error {
+		route := common.NewRoute(path)
+		if err := writer.Write(&common.RouteRecord{Type: "route", Route: &route}); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+
+	switch {
+	case err == context.Canceled:
+		return
+	case err != nil:
+		writer.Write(&common.RouteRecord{Type: "error", Stats: &stats, Error: err.Error()})
+	default:
+		writer.Write(&common.RouteRecord{Type: "summary", Stats: &stats})
+	}
+	c.Writer.Flush()
+}
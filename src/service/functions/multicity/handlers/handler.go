@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/criteria"
+	"service/common/graph"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Handle api call handler. Consumes multipart/form-data, produces json
+func Handle(c *gin.Context) {
+	var req common.MultiCityDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	legs, err := common.ParseLegs(req.Legs)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "legs", err)
+		return
+	}
+
+	start := time.Now()
+	data, err := common.LoadData(req.Data, req.DatasetID)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+	api.Dataset(c, "data", req.DatasetID, data)
+	api.Timing(c, "parse", start)
+
+	start = time.Now()
+	g := common.NewFlightsGraph(data)
+	api.Timing(c, "graph", start)
+
+	start = time.Now()
+	itineraries, stats, err := common.SearchItinerariesContext(c.Request.Context(), g, legs, req.MaxFlightsInRoute, req.Budget())
+	if err != nil {
+		api.SearchError(c, err)
+		return
+	}
+	api.Timing(c, "search", start)
+
+	items := criteria.NewDefaultSet()
+	byPath := make(map[*graph.Path]*common.Itinerary)
+
+	for _, itinerary := range itineraries {
+		path := itinerary.Path()
+		byPath[path] = itinerary
+		for _, criterion := range items {
+			criterion.Apply(path)
+		}
+	}
+
+	result := gin.H{"truncated": stats.Truncated, "reason": stats.Reason}
+
+	for key, criterion := range items {
+		var ranked []*common.Itinerary
+		for _, p := range criterion.GetResult() {
+			ranked = append(ranked, byPath[p])
+		}
+		result[key] = ranked
+	}
+
+	api.Respond(c, http.StatusOK, result)
+}
@@ -1,76 +1,65 @@
 package handlers
 
 import (
-	"io/ioutil"
 	"net/http"
 	"service/common"
-
-	"encoding/xml"
+	"service/common/api"
+	"service/common/criteria"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 //Handle api call handler. Consumes multipart/form-data, produces json
 func Handle(c *gin.Context) {
-	var req common.SingleDataRequest
+	var req common.RankDataRequest
 
 	if err := c.ShouldBind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		api.BindError(c, err, &req)
 		return
 	}
 
-	file, _ := req.Data.Open()
-	content, _ := ioutil.ReadAll(file)
-
-	var data common.AirFareSearchResponse
-	if err := xml.Unmarshal(content, &data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	items, err := criteria.NewSet(req.Criteria)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "criteria", err)
 		return
 	}
 
-	g := common.NewFlightsGraph(&data)
-
-	minCost := NewMinimumCostCriterion()
-	maxCost := NewMaximumCostCriterion()
-	minTime := NewMinimumTimeCriterion()
-	maxTime := NewMaximumTimeCriterion()
-	optimal := NewOptimalCriterion(&OptimalCriterionWeights{
-		Time:            2,
-		Cost:            1,
-		NumberOfFlights: 3,
-	})
+	start := time.Now()
+	data, err := common.LoadData(req.Data, req.DatasetID)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+	api.Dataset(c, "data", req.DatasetID, data)
+	api.Timing(c, "parse", start)
 
-	g.SearchOptimalPaths(req.Source, req.Destination, req.MaxFlightsInRoute, minCost, maxCost, minTime, maxTime, optimal)
+	start = time.Now()
+	g := common.NewFlightsGraph(data)
+	api.Timing(c, "graph", start)
 
-	items := map[string]*Criterion{
-		"minCost": minCost,
-		"maxCost": maxCost,
-		"minTime": minTime,
-		"maxTime": maxTime,
-		"optimal": optimal,
+	start = time.Now()
+	stats, err := items.Search(c.Request.Context(), g, req.Source, req.Destination, req.MaxFlightsInRoute, req.Budget())
+	if err != nil {
+		api.SearchError(c, err)
+		return
 	}
+	api.Timing(c, "search", start)
 
-	result := make(map[string]interface{})
+	result := gin.H{"truncated": stats.Truncated, "reason": stats.Reason}
 
 	for key, criterion := range items {
 		var routes []common.Route
-		paths := criterion.GetResult()
-		for _, p := range paths {
-			var flights []*common.FlightItem
-			edges := p.Edges()
-			for _, edge := range edges {
-				flights = append(flights, edge.(*common.FlightItem))
-			}
-			routes = append(routes, common.Route{Flights: flights})
+		for _, p := range criterion.GetResult() {
+			routes = append(routes, common.NewRoute(p))
 		}
 		result[key] = routes
 	}
 
-	result["success"] = true
-	c.JSON(http.StatusOK, result)
+	api.Respond(c, http.StatusOK, result)
 
-	/*paths := maxTime.GetResult()
-	fmt.Println(maxTime.Value)
+	/*paths := items["maxTime"].GetResult()
+	fmt.Println(items["maxTime"].Value)
 	fmt.Printf("Got %d paths", len(paths))
 	fmt.Println()
 	for idx, r := range paths {
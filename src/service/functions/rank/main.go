@@ -1,6 +1,7 @@
 package main
 
 import (
+	"service/common/api"
 	"service/common/server"
 	"service/functions/rank/handlers"
 
@@ -11,5 +12,8 @@ func main() {
 	router := gin.Default()
 	router.POST("/rank", handlers.Handle)
 
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/rank", handlers.Handle)
+
 	server.Start(router)
 }
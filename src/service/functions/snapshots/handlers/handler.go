@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/store"
+	"service/common/watch"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var notifier = watch.NewNotifierFromEnv()
+
+//Create api call handler. Consumes multipart/form-data, adds snapshot to series
+func Create(c *gin.Context) {
+	var req common.SnapshotDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+	if err := notifier.Start(s); err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	var content []byte
+	if req.Data != nil {
+		content, err = common.ReadUpload(req.Data)
+	} else if req.DatasetID != "" {
+		content, err = s.Get(req.DatasetID)
+	} else {
+		err = common.ErrNoData
+	}
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+
+	data, err := common.ParseAirFareSearchResponse(content)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+
+	requestTime, err := common.ParseRequestTime(data.RequestTime)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidData, "data", err)
+		return
+	}
+
+	id, err := s.Put(content)
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	snapshot := common.Snapshot{
+		DatasetID:   id,
+		RequestTime: requestTime,
+		AddedAt:     time.Now().UTC(),
+	}
+
+	series, latest, err := common.AddSnapshot(s, req.Series, snapshot)
+	if err != nil {
+		api.StoreError(c, "series", err)
+		return
+	}
+
+	//watches follow the latest state of series, re-submits and backfills don't change it
+	var deliveries []*watch.Delivery
+	var failures []watch.EvaluationError
+	if latest {
+		var previous *common.AirFareSearchResponse
+		if count := len(series.Snapshots); count > 1 {
+			if previous, err = common.LoadDataset(series.Snapshots[count-2].DatasetID); err != nil {
+				api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+				return
+			}
+		}
+
+		deliveries, failures, err = watch.EvaluateAll(c.Request.Context(), s, notifier, req.Series, snapshot, previous, data)
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			api.SearchError(c, err)
+			return
+		}
+		if err != nil {
+			api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+			return
+		}
+	}
+
+	api.Dataset(c, "data", id, data)
+	api.Respond(c, http.StatusCreated, gin.H{"datasetId": id, "series": series, "deliveries": deliveries, "watchErrors": failures})
+}
+
+//Get api call handler. Produces series snapshots list
+func Get(c *gin.Context) {
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	series, err := common.LoadSeries(s, c.Param("series"))
+	if err != nil {
+		api.StoreError(c, "series", err)
+		return
+	}
+	if len(series.Snapshots) == 0 {
+		api.StoreError(c, "series", store.ErrNotFound)
+		return
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"series": series})
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/snapshots/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.Default()
+	router.POST("/snapshots", handlers.Create)
+	router.GET("/snapshots/:series", handlers.Get)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/snapshots", handlers.Create)
+	v2.GET("/snapshots/:series", handlers.Get)
+
+	server.Start(router)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"service/common"
+	"service/common/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Handle api call handler. Consumes multipart/form-data, produces json data quality report
+func Handle(c *gin.Context) {
+	var req common.ValidateDataRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	failOn := common.SeverityCritical
+	if req.FailOn != "" {
+		severity, err := common.ParseSeverity(req.FailOn)
+		if err != nil {
+			api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "fail_on", err)
+			return
+		}
+		failOn = severity
+	}
+
+	content, err := common.LoadContent(req.Data, req.DatasetID)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+
+	report, err := common.NewQualityReport(content)
+	if err != nil {
+		api.LoadError(c, "", err)
+		return
+	}
+
+	passed := report.Passed(failOn)
+	response := gin.H{"report": report, "passed": passed, "failOn": failOn}
+	if !passed && req.FailOn != "" {
+		api.Fail(c, http.StatusUnprocessableEntity, api.CodeInvalidData, "data", fmt.Errorf("data has issues of %s severity or higher", failOn), response)
+		return
+	}
+	api.Respond(c, http.StatusOK, response)
+}
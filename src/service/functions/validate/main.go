@@ -0,0 +1,19 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/validate/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.New()
+	router.POST("/validate", handlers.Handle)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/validate", handlers.Handle)
+
+	server.Start(router)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"service/common"
+	"service/common/api"
+	"service/common/store"
+	"service/common/watch"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Create api call handler. Consumes form or json, registers watch
+func Create(c *gin.Context) {
+	var req common.WatchRequest
+
+	if err := c.ShouldBind(&req); err != nil {
+		api.BindError(c, err, &req)
+		return
+	}
+
+	w, err := watch.New(&req)
+	if err != nil {
+		api.Fail(c, http.StatusBadRequest, api.CodeInvalidParameter, "", err)
+		return
+	}
+
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	if err := w.Save(s); err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	api.Respond(c, http.StatusCreated, gin.H{"watch": w})
+}
+
+//List api call handler. Produces all registered watches
+func List(c *gin.Context) {
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	watches, err := watch.List(s)
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"watches": watches})
+}
+
+//Get api call handler. Produces watch
+func Get(c *gin.Context) {
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	w, err := watch.Load(s, c.Param("id"))
+	if err != nil {
+		api.StoreError(c, "id", err)
+		return
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"watch": w})
+}
+
+//Delete api call handler. Removes watch and its delivery log
+func Delete(c *gin.Context) {
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	if err := watch.Delete(s, c.Param("id")); err != nil {
+		api.StoreError(c, "id", err)
+		return
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"id": c.Param("id")})
+}
+
+//Deliveries api call handler. Produces watch delivery log
+func Deliveries(c *gin.Context) {
+	s, err := store.Default()
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	if _, err := watch.Load(s, c.Param("id")); err != nil {
+		api.StoreError(c, "id", err)
+		return
+	}
+
+	deliveries, err := watch.LoadDeliveries(s, c.Param("id"))
+	if err != nil {
+		api.Fail(c, http.StatusInternalServerError, api.CodeInternal, "", err)
+		return
+	}
+
+	api.Respond(c, http.StatusOK, gin.H{"deliveries": deliveries})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"service/common/api"
+	"service/common/server"
+	"service/functions/watches/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	router := gin.Default()
+	router.POST("/watches", handlers.Create)
+	router.GET("/watches", handlers.List)
+	router.GET("/watches/:id", handlers.Get)
+	router.DELETE("/watches/:id", handlers.Delete)
+	router.GET("/watches/:id/deliveries", handlers.Deliveries)
+
+	v2 := router.Group("/v2", api.V2())
+	v2.POST("/watches", handlers.Create)
+	v2.GET("/watches", handlers.List)
+	v2.GET("/watches/:id", handlers.Get)
+	v2.DELETE("/watches/:id", handlers.Delete)
+	v2.GET("/watches/:id/deliveries", handlers.Deliveries)
+
+	server.Start(router)
+}
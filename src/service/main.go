@@ -3,19 +3,53 @@ package main
 import (
 	"github.com/gin-gonic/gin"
 
+	"service/common/api"
 	"service/common/server"
+	batch "service/functions/batch/handlers"
+	compareItineraries "service/functions/compare-itineraries/handlers"
+	compareRank "service/functions/compare-rank/handlers"
 	compareRoutes "service/functions/compare-routes/handlers"
 	compare "service/functions/compare/handlers"
+	count "service/functions/count/handlers"
+	datasets "service/functions/datasets/handlers"
+	history "service/functions/history/handlers"
 	list "service/functions/list/handlers"
+	multicity "service/functions/multicity/handlers"
 	rank "service/functions/rank/handlers"
+	snapshots "service/functions/snapshots/handlers"
+	validate "service/functions/validate/handlers"
+	watches "service/functions/watches/handlers"
 )
 
-func main() {
-	router := gin.New()
+//register adds endpoints to router, v1 and v2 share handlers
+func register(router gin.IRoutes) {
 	router.POST("/compare", compare.Handle)
 	router.POST("/compare/routes", compareRoutes.Handle)
+	router.POST("/compare/itineraries", compareItineraries.Handle)
+	router.POST("/compare/rank", compareRank.Handle)
 	router.POST("/list", list.Handle)
 	router.POST("/rank", rank.Handle)
+	router.POST("/count", count.Handle)
+	router.POST("/multicity", multicity.Handle)
+	router.POST("/batch", batch.Handle)
+	router.POST("/validate", validate.Handle)
+	router.POST("/datasets", datasets.Create)
+	router.GET("/datasets/:id", datasets.Get)
+	router.DELETE("/datasets/:id", datasets.Delete)
+	router.POST("/snapshots", snapshots.Create)
+	router.GET("/snapshots/:series", snapshots.Get)
+	router.GET("/history", history.Handle)
+	router.POST("/watches", watches.Create)
+	router.GET("/watches", watches.List)
+	router.GET("/watches/:id", watches.Get)
+	router.DELETE("/watches/:id", watches.Delete)
+	router.GET("/watches/:id/deliveries", watches.Deliveries)
+}
+
+func main() {
+	router := gin.New()
+	register(router)
+	register(router.Group("/v2", api.V2()))
 
 	server.Start(router)
 }